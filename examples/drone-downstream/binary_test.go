@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug/plugtest"
+)
+
+func TestMain(m *testing.M) {
+	plugtest.Main(m)
+}
+
+func TestBinary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles the plugin")
+	}
+	t.Run("usage error", func(t *testing.T) {
+		b := plugtest.Binary(t, ".")
+		b.AssertExitCode(1)
+		b.AssertStderrContains("you must provide your Drone access token.")
+	})
+	t.Run("invalid option", func(t *testing.T) {
+		b := plugtest.Binary(t, ".")
+		b.SetPluginVars(map[string]string{
+			"token":  "tokenvalue",
+			"server": "servervalue",
+			"fork":   "koo",
+		})
+		b.AssertExitCode(1)
+		usage := strings.Join(strings.Fields(b.Stderr()), " ")
+		if !strings.Contains(usage, "fork Trigger a new build for a repository file option: fork_file set by: fork value: false **ERROR**: parse error") {
+			t.Errorf("expected a parse error for fork:\n%s", b.Stderr())
+		}
+	})
+	t.Run("debug", func(t *testing.T) {
+		b := plugtest.Binary(t, ".")
+		b.SetPluginVars(map[string]string{
+			"plugin_debug": "1",
+			"version":      "1.0",
+		})
+		b.AssertExitCode(1)
+		b.AssertStderrContains("drone plugins debug mode is active!")
+		b.AssertStderrContains("[env] PLUGIN_VERSION=1.0")
+		b.AssertStderrContains("you must provide your Drone access token.")
+	})
+	t.Run("env file", func(t *testing.T) {
+		b := plugtest.Binary(t, ".")
+		dir := b.Workdir()
+		writeFile(t, filepath.Join(dir, "plugin.env"), "PLUGIN_TOKEN=tokenvalue\n")
+		b.SetArgs("-env_file", "plugin.env", "-server", "servervalue")
+		b.AssertExitCode(0)
+		b.AssertStderrContains("success!")
+		b.AssertStdout("")
+	})
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/drone-plug/drone-plugins-go/plug/plugtest"
@@ -28,10 +29,8 @@ func TestExecOK(t *testing.T) {
 }
 
 func TestOutputs(t *testing.T) {
-	// this test is mostly here for testing usage formatting output, set silece to false to always print output
-	// const silence = false
-	const silence = true
-
+	// the usage formatting output is compared against testdata/outputs-*.golden,
+	// run the tests with -plugtest.update after changing it.
 	type testCase struct {
 		plugvars map[string]string
 		envvars  map[string]string
@@ -72,8 +71,7 @@ func TestOutputs(t *testing.T) {
 		},
 	}
 
-ts:
-	for _, tc := range tests {
+	for i, tc := range tests {
 		p := &Plugin{}
 		pt := plugtest.New(t, p)
 		pt.SetPluginVars(tc.plugvars)
@@ -85,24 +83,6 @@ ts:
 		} else {
 			pt.AssertSuccess()
 		}
-		if silence {
-			continue ts
-		}
-		t.Logf(`
-
-*************************************
-  args: none
-  plugin vars: %v
-  env vars: %v
-  err: %v
-  output:
-
-%s`,
-			tc.plugvars,
-			tc.envvars,
-			pt.Err,
-			pt.Output())
-
+		pt.AssertGolden(fmt.Sprintf("testdata/outputs-%d.golden", i))
 	}
-
 }
@@ -0,0 +1,106 @@
+package plug
+
+import (
+	"fmt"
+	"strings"
+)
+
+// alias is registered by FlagSet.Alias.
+type alias struct {
+	ref   interface{}
+	names []string
+}
+
+// Alias registers old setting names for the option bound to ref, typically
+// used when a setting has been renamed. A setting given by an alias name is
+// used as the option value with a deprecation warning naming the replacement
+// unless the option is set by its own name as well, setting both to
+// different values is an usage error. Aliases are listed in the usage output
+// and in the Aliases of OptionState.
+func (fs *FlagSet) Alias(ref interface{}, names ...string) {
+	fs.aliases = append(fs.aliases, alias{ref: ref, names: names})
+}
+
+// registerAliases records the alias names registered by FlagSet.Alias by
+// flag name.
+func (s *Service) registerAliases() {
+	for _, a := range s.pfs.aliases {
+		flg, err := s.findEnvFlag(a.ref)
+		if err != nil {
+			s.log.programmingFatalf("Alias: %v", err)
+		}
+		s.aliases[flg.Flag.Name] = append(s.aliases[flg.Flag.Name], a.names...)
+	}
+}
+
+// parseAliases sets options from their alias settings in env. It must be
+// called after the EnvSet has been parsed. Returns false if there were
+// conflicting or invalid alias settings.
+func (s *Service) parseAliases(env map[string]string) bool {
+	ok := true
+	for _, a := range s.pfs.aliases {
+		flg, err := s.findEnvFlag(a.ref)
+		if err != nil {
+			s.log.programmingFatalf("Alias: %v", err)
+		}
+		name := fmtFlagName(flg)
+		for _, aliasName := range a.names {
+			value, set := env[settingEnvName(aliasName)]
+			if !set {
+				continue
+			}
+			s.log.Debugf("[alias] '%s' set by alias '%s': %s", flg.Flag.Name, aliasName, value)
+			if flg.IsSelfSet || s.setBy[flg.Flag.Name] != "" {
+				if value != flg.Value {
+					s.addUsageError(flg.Flag.Name, fmt.Sprintf("%s and its alias %s are both set to different values", name, aliasName))
+					ok = false
+				}
+				s.addWarning(flg.Flag.Name, fmt.Sprintf("deprecated: %s is an alias for %s, remove it", aliasName, name))
+				continue
+			}
+			if err := s.fs.Set(flg.Flag.Name, value); err != nil {
+				s.addUsageError(flg.Flag.Name, fmt.Sprintf("invalid value for alias %s: %v", aliasName, err))
+				ok = false
+				continue
+			}
+			s.setBy[flg.Flag.Name] = "alias " + aliasName
+			s.aliasValues[flg.Flag.Name] = value
+			s.addWarning(flg.Flag.Name, fmt.Sprintf("deprecated: %s is an alias, use %s", aliasName, name))
+		}
+	}
+	return ok
+}
+
+// aliasedValues returns the values of the options set by an alias by flag
+// name.
+func (s *Service) aliasedValues() map[string]string {
+	values := make(map[string]string)
+	for name := range s.aliasValues {
+		values[name] = s.fs.Lookup(name).Value.String()
+	}
+	return values
+}
+
+// checkAliasFlags registers usage errors for options set by an alias which
+// were given as a flag with a different value as well. It must be called
+// after the flags have been parsed with the aliasedValues from before.
+// Returns false if there were conflicts.
+func (s *Service) checkAliasFlags(aliased map[string]string) bool {
+	ok := true
+	for _, a := range s.pfs.aliases {
+		flg, err := s.findEnvFlag(a.ref)
+		if err != nil {
+			s.log.programmingFatalf("Alias: %v", err)
+		}
+		value, set := aliased[flg.Flag.Name]
+		if !set || flg.Flag.Value.String() == value {
+			continue
+		}
+		aliasName := strings.TrimPrefix(s.setBy[flg.Flag.Name], "alias ")
+		s.addUsageError(flg.Flag.Name, fmt.Sprintf("-%s and its alias %s are both set to different values", flg.Flag.Name, aliasName))
+		delete(s.setBy, flg.Flag.Name)
+		delete(aliased, flg.Flag.Name)
+		ok = false
+	}
+	return ok
+}
@@ -0,0 +1,128 @@
+package plug_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type aliasPlugin struct {
+	Repos []string
+}
+
+func (p *aliasPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringSliceVar(&p.Repos, "repos", "repositories to trigger")
+	fs.Alias(&p.Repos, "repositories")
+}
+
+func (p *aliasPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestAlias(t *testing.T) {
+	p := &aliasPlugin{}
+	res, err := plug.Invoke(context.Background(), p, plug.Settings{
+		"repositories": []string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Repos, []string{"a", "b"}) {
+		t.Errorf("expected repos to be set by alias, got %v", p.Repos)
+	}
+	expected := "plugin option 'repos' warning: deprecated: repositories is an alias, use repos\n"
+	if res.Log != expected {
+		t.Errorf("unexpected output:\n%s", res.Log)
+	}
+}
+
+func TestAliasSameValue(t *testing.T) {
+	p := &aliasPlugin{}
+	_, err := plug.Invoke(context.Background(), p, plug.Settings{
+		"repos":        []string{"a"},
+		"repositories": []string{"a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAliasConflict(t *testing.T) {
+	res, err := plug.Invoke(context.Background(), &aliasPlugin{}, plug.Settings{
+		"repos":        []string{"a"},
+		"repositories": []string{"b"},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	errs := err.(*plug.ExecError).UsageErrors["repos"]
+	if len(errs) != 1 || !strings.Contains(errs[0], "are both set to different values") {
+		t.Errorf("unexpected usage errors: %v", errs)
+	}
+	if !strings.Contains(res.Log, "repositories (deprecated)") {
+		t.Errorf("expected alias in usage output:\n%s", res.Log)
+	}
+}
+
+func TestAliasFlagConflict(t *testing.T) {
+	_, err := plug.Invoke(context.Background(), &aliasPlugin{}, plug.Settings{
+		"repositories": []string{"b"},
+	}, plug.WithArgs("-repos", "a"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	errs := err.(*plug.ExecError).UsageErrors["repos"]
+	if len(errs) != 1 || errs[0] != "-repos and its alias repositories are both set to different values" {
+		t.Errorf("unexpected usage errors: %v", errs)
+	}
+
+	p := &aliasPlugin{}
+	_, err = plug.Invoke(context.Background(), p, plug.Settings{
+		"repositories": []string{"a"},
+	}, plug.WithArgs("-repos", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type aliasInterpolatePlugin struct {
+	Tag string
+}
+
+func (p *aliasInterpolatePlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Tag, "tag", "", "image tag")
+	fs.Alias(&p.Tag, "image_tag")
+	fs.Interpolate(&p.Tag)
+}
+
+func (p *aliasInterpolatePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestAliasInterpolate(t *testing.T) {
+	p := &aliasInterpolatePlugin{}
+	_, err := plug.Invoke(context.Background(), p, plug.Settings{
+		"image_tag": "${DRONE_TAG##v}",
+	}, plug.WithEnv(map[string]string{"DRONE_TAG": "v1.2.3"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Tag != "1.2.3" {
+		t.Errorf("expected interpolated alias value, got %q", p.Tag)
+	}
+}
+
+func TestAliasOptions(t *testing.T) {
+	var aliases []string
+	for _, o := range plug.Options(&aliasPlugin{}) {
+		if o.Name == "repos" {
+			aliases = o.Aliases
+		}
+	}
+	if !reflect.DeepEqual(aliases, []string{"repositories"}) {
+		t.Errorf("unexpected aliases: %v", aliases)
+	}
+}
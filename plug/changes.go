@@ -0,0 +1,207 @@
+package plug
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ChangedFile is a file changed by a build, see ChangedFiles.
+type ChangedFile struct {
+	Path    string
+	OldPath string // path before a rename or copy
+	Status  string // A, C, D, M, R or T like git diff --name-status
+}
+
+// ChangedFiles returns the files changed by the build described by d using
+// git in dir, the working directory if dir is empty. Git is run by the
+// Commander of the service log belongs to, log may be nil to run it with
+// os/exec. Pull
+// requests are compared against the merge base with the target branch and
+// pushes against the commit before the push. If there is no usable commit
+// to compare with, for example when a branch is created, the files changed
+// by the commit itself are returned.
+func ChangedFiles(ctx context.Context, log *Logger, dir string, d Drone) ([]ChangedFile, error) {
+	after := d.Commit.Sha
+	if after == "" {
+		after = "HEAD"
+	}
+	var args []string
+	switch {
+	case d.Build.IsPullRequest() && d.Commit.TargetBranch != "":
+		base := "origin/" + d.Commit.TargetBranch
+		if _, err := gitOutput(ctx, log, dir, "rev-parse", "--verify", "-q", base+"^{commit}"); err != nil {
+			base = d.Commit.TargetBranch
+		}
+		args = []string{"diff", "--name-status", "-M", "-z", base + "..." + after}
+	case d.Commit.Before != "" && strings.Trim(d.Commit.Before, "0") != "":
+		args = []string{"diff", "--name-status", "-M", "-z", d.Commit.Before, after}
+	default:
+		args = []string{"diff-tree", "--no-commit-id", "--root", "-r", "--name-status", "-M", "-z", after}
+	}
+	out, err := gitOutput(ctx, log, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseNameStatus(out)
+}
+
+// parseNameStatus parses the output of git diff --name-status -z.
+func parseNameStatus(out string) ([]ChangedFile, error) {
+	var files []ChangedFile
+	fields := strings.Split(strings.TrimRight(out, "\x00"), "\x00")
+	for i := 0; i < len(fields) && fields[i] != ""; i++ {
+		status := fields[i][:1]
+		if i+1 >= len(fields) {
+			return nil, fmt.Errorf("unexpected git diff output: %q", out)
+		}
+		f := ChangedFile{Status: status, Path: fields[i+1]}
+		i++
+		if status == "R" || status == "C" {
+			if i+1 >= len(fields) {
+				return nil, fmt.Errorf("unexpected git diff output: %q", out)
+			}
+			f.OldPath, f.Path = f.Path, fields[i+1]
+			i++
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// MatchPath reports whether path matches c using path globs, where * and ?
+// do not match slashes and ** matches any number of directories. Invalid
+// patterns never match, Set reports them as errors.
+func (c Constraint) MatchPath(path string) bool {
+	for _, pattern := range c.Exclude {
+		if c.matchPathGlob(pattern, path) {
+			return false
+		}
+	}
+	if len(c.Include) == 0 {
+		return true
+	}
+	for _, pattern := range c.Include {
+		if c.matchPathGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathGlob reports whether path matches the glob pattern, using the
+// regexp compiled by Set if there is one.
+func (c Constraint) matchPathGlob(pattern, path string) bool {
+	re := c.globs[pattern]
+	if re == nil {
+		var err error
+		if re, err = compilePathGlob(pattern); err != nil {
+			return false
+		}
+	}
+	return re.MatchString(path)
+}
+
+// compileGlobs compiles the path globs of c.
+func (c *Constraint) compileGlobs() error {
+	globs := make(map[string]*regexp.Regexp)
+	for _, pattern := range append(append([]string(nil), c.Include...), c.Exclude...) {
+		re, err := compilePathGlob(pattern)
+		if err != nil {
+			return err
+		}
+		globs[pattern] = re
+	}
+	c.globs = globs
+	return nil
+}
+
+// MatchFiles returns the files matching c. Renamed files match if either
+// their old or new path matches.
+func (c Constraint) MatchFiles(files []ChangedFile) []ChangedFile {
+	var res []ChangedFile
+	for _, f := range files {
+		if c.MatchPath(f.Path) || (f.OldPath != "" && c.MatchPath(f.OldPath)) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// String implements flag.Value.
+func (c *Constraint) String() string {
+	if c == nil || c.IsEmpty() {
+		return ""
+	}
+	if len(c.Exclude) == 0 {
+		return strings.Join(c.Include, ",")
+	}
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+// Set implements flag.Value. The value is either a comma separated list of
+// include patterns or JSON as accepted by UnmarshalJSON. The patterns are
+// compiled as path globs, an invalid pattern is an error.
+func (c *Constraint) Set(value string) error {
+	var v Constraint
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") || strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				v.Include = append(v.Include, p)
+			}
+		}
+	}
+	if err := v.compileGlobs(); err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// PathsVar defines the paths option which limits the plugin to builds that
+// change matching files:
+//
+//	paths:
+//	  include: [ services/api/** ]
+//	  exclude: [ "**/*.md" ]
+//
+// When the option is set and none of the files returned by ChangedFiles for
+// the DRONE_WORKSPACE directory match, Exec is skipped. If the changed files can not be determined a
+// warning is registered and Exec runs.
+func (fs *FlagSet) PathsVar(c *Constraint) {
+	fs.Var(c, "paths", "only run when files matching these globs changed")
+	fs.paths = c
+}
+
+// skipUnchanged returns a middleware which skips exec when no changed file
+// matches the paths option.
+func (s *Service) skipUnchanged(env map[string]string) Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, log *Logger) error {
+			paths := s.pfs.paths
+			if paths == nil || paths.IsEmpty() {
+				return next(ctx, log)
+			}
+			files, err := ChangedFiles(ctx, log, env["DRONE_WORKSPACE"], ParseDrone(env))
+			if err != nil {
+				log.Warnf(paths, "could not detect changed files, running anyway: %v", err)
+				return next(ctx, log)
+			}
+			matched := paths.MatchFiles(files)
+			log.Debugf("[paths] %d of %d changed files match", len(matched), len(files))
+			if len(matched) == 0 {
+				log.Println("skipping, no changed files match paths")
+				return nil
+			}
+			return next(ctx, log)
+		}
+	}
+}
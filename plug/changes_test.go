@@ -0,0 +1,131 @@
+package plug_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+// changesRepo creates a git repository with a second commit which adds,
+// modifies and renames files.
+func changesRepo(t *testing.T) (dir, before, after string) {
+	dir = gitRepo(t)
+	write := func(name, content string) {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("README.md", "hello\n")
+	write("services/api/old.go", "package api\n\n// a file long enough to be detected as renamed\n")
+	gitRun(t, dir, "add", "-A")
+	gitRun(t, dir, "commit", "-q", "-m", "Add files")
+	before = gitRun(t, dir, "rev-parse", "HEAD")
+
+	write("README.md", "hello world\n")
+	write("services/web/index.html", "<html></html>\n")
+	gitRun(t, dir, "mv", "services/api/old.go", "services/api/new.go")
+	gitRun(t, dir, "add", "-A")
+	gitRun(t, dir, "commit", "-q", "-m", "Change files")
+	after = gitRun(t, dir, "rev-parse", "HEAD")
+	return dir, before, after
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir, before, after := changesRepo(t)
+
+	d := plug.Drone{Commit: plug.Commit{Sha: after, Before: before}}
+	files, err := plug.ChangedFiles(context.Background(), nil, dir, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []plug.ChangedFile{
+		{Path: "README.md", Status: "M"},
+		{Path: "services/api/new.go", OldPath: "services/api/old.go", Status: "R"},
+		{Path: "services/web/index.html", Status: "A"},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected changed files: %+v", files)
+	}
+
+	// without a before sha only the files of the commit itself are compared
+	d.Commit.Before = "0000000000000000000000000000000000000000"
+	if files, err = plug.ChangedFiles(context.Background(), nil, dir, d); err != nil || len(files) != 3 {
+		t.Errorf("unexpected changed files: %+v %v", files, err)
+	}
+
+	c := plug.Constraint{Include: []string{"services/**"}, Exclude: []string{"**/*.html"}}
+	matched := c.MatchFiles(files)
+	if len(matched) != 1 || matched[0].Path != "services/api/new.go" {
+		t.Errorf("unexpected matched files: %+v", matched)
+	}
+	// renamed files also match by their old path
+	c = plug.Constraint{Include: []string{"services/api/old.go"}}
+	if matched := c.MatchFiles(files); len(matched) != 1 {
+		t.Errorf("unexpected matched files: %+v", matched)
+	}
+}
+
+type pathsPlugin struct {
+	Paths plug.Constraint
+	ran   bool
+}
+
+func (p *pathsPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.PathsVar(&p.Paths)
+}
+
+func (p *pathsPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	p.ran = true
+	return nil
+}
+
+func TestPathsVar(t *testing.T) {
+	dir, before, after := changesRepo(t)
+	d := plug.Drone{Commit: plug.Commit{Sha: after, Before: before}}
+
+	for paths, ran := range map[string]bool{
+		"":                         true,
+		"services/api/**":          true,
+		"docs/**,*.txt":            false,
+		`{"exclude": ["**/*.md"]}`: true,
+		`{"exclude": ["**"]}`:      false,
+	} {
+		p := &pathsPlugin{}
+		res, err := plug.Invoke(context.Background(), p, plug.Settings{"paths": paths},
+			plug.WithDrone(d), plug.WithEnv(map[string]string{"DRONE_WORKSPACE": dir}))
+		if err != nil {
+			t.Fatalf("%s: %v", paths, err)
+		}
+		if p.ran != ran {
+			t.Errorf("%s: expected ran to be %v:\n%s", paths, ran, res.Log)
+		}
+		if !ran && !strings.Contains(res.Log, "skipping") {
+			t.Errorf("%s: expected skip message:\n%s", paths, res.Log)
+		}
+	}
+}
+
+func TestPathsVarInvalid(t *testing.T) {
+	p := &pathsPlugin{}
+	res, err := plug.Invoke(context.Background(), p, plug.Settings{"paths": "services/[api/**"})
+	if err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+	if p.ran || !strings.Contains(res.Log, `invalid path glob "services/[api/**": missing ]`) {
+		t.Errorf("expected a usage error:\n%s", res.Log)
+	}
+	var c plug.Constraint
+	if err := c.Set(`{"exclude": ["[z-a]"]}`); err == nil {
+		t.Error("expected an error for an invalid class")
+	}
+}
@@ -0,0 +1,124 @@
+package plug
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Commander runs external commands for plugins. The default runs them with
+// os/exec, tests can install another one with the SetCommander service
+// option.
+type Commander interface {
+	Run(ctx context.Context, cmd *Cmd) error
+}
+
+// CommanderFunc adapts a function to a Commander.
+type CommanderFunc func(ctx context.Context, cmd *Cmd) error
+
+// Run calls fn.
+func (fn CommanderFunc) Run(ctx context.Context, cmd *Cmd) error {
+	return fn(ctx, cmd)
+}
+
+// SetCommander is a NewService option to run the commands created with
+// Logger.Command and the git commands of the library with c instead of
+// os/exec.
+func SetCommander(c Commander) ServiceOption {
+	if c == nil {
+		log.Fatal("Commander is nil")
+	}
+	return func(s *Service) {
+		s.commander = c
+	}
+}
+
+// Cmd is an external command created with Logger.Command.
+type Cmd struct {
+	Args   []string  // program name and arguments
+	Env    []string  // KEY=value pairs added to the environment of the process
+	Dir    string    // working directory, the current directory if empty
+	Stdin  io.Reader // nil reads nothing
+	Stdout io.Writer // Logger.Stdout by default
+	Stderr io.Writer // Logger.Stderr by default
+
+	log *Logger
+}
+
+// CommandError is returned when a command exits with a non zero exit code.
+type CommandError struct {
+	Args     []string
+	ExitCode int
+	Stderr   string // captured standard error, set by Cmd.Output
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s: exit status %d", strings.Join(e.Args, " "), e.ExitCode)
+	if e.Stderr != "" {
+		msg += ": " + strings.TrimSpace(e.Stderr)
+	}
+	return msg
+}
+
+// Command returns a command which runs name with args using the service
+// Commander. Commands are logged in debug mode.
+func (l *Logger) Command(name string, args ...string) *Cmd {
+	return &Cmd{
+		Args:   append([]string{name}, args...),
+		Stdout: l.Stdout(),
+		Stderr: l.Stderr(),
+		log:    l,
+	}
+}
+
+// Run runs the command and waits for it to finish.
+func (c *Cmd) Run(ctx context.Context) error {
+	if len(c.Args) == 0 {
+		return errors.New("command has no program name")
+	}
+	var commander Commander = CommanderFunc(execCommand)
+	if c.log != nil {
+		c.log.Debugf("[command] %s", strings.Join(c.Args, " "))
+		if c.log.s != nil && c.log.s.commander != nil {
+			commander = c.log.s.commander
+		}
+	}
+	return commander.Run(ctx, c)
+}
+
+// Output runs the command and returns its standard output. Standard error
+// is included in a returned *CommandError.
+func (c *Cmd) Output(ctx context.Context) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	err := c.Run(ctx)
+	var cerr *CommandError
+	if errors.As(err, &cerr) && cerr.Stderr == "" {
+		cerr.Stderr = stderr.String()
+	}
+	return stdout.Bytes(), err
+}
+
+// execCommand runs cmd with os/exec.
+func execCommand(ctx context.Context, c *Cmd) error {
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	err := cmd.Run()
+	var eerr *exec.ExitError
+	if errors.As(err, &eerr) {
+		return &CommandError{Args: c.Args, ExitCode: eerr.ExitCode()}
+	}
+	return err
+}
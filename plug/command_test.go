@@ -0,0 +1,48 @@
+package plug_test
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type commandPlugin struct {
+	output string
+	err    error
+}
+
+func (p *commandPlugin) SetFlags(fs *plug.FlagSet) {}
+
+func (p *commandPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	cmd := log.Command("sh", "-c", "echo $GREETING; cat")
+	cmd.Env = []string{"GREETING=hello"}
+	cmd.Stdin = strings.NewReader("world\n")
+	if err := cmd.Run(ctx); err != nil {
+		return err
+	}
+	out, err := log.Command("sh", "-c", "echo out; echo failed >&2; exit 3").Output(ctx)
+	p.output, p.err = string(out), err
+	return nil
+}
+
+func TestCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	p := &commandPlugin{}
+	res, err := plug.Invoke(context.Background(), p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stdout != "hello\nworld\n" {
+		t.Errorf("unexpected stdout: %q", res.Stdout)
+	}
+	var cerr *plug.CommandError
+	if p.output != "out\n" || !errors.As(p.err, &cerr) || cerr.ExitCode != 3 || cerr.Stderr != "failed\n" {
+		t.Errorf("unexpected output or error: %q %v", p.output, p.err)
+	}
+}
@@ -0,0 +1,155 @@
+package plug
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Trailer is a git trailer such as "Signed-off-by: Name <email>" from the
+// last paragraph of a commit message.
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// ConventionalCommit holds the parts of a commit subject which follows the
+// Conventional Commits specification: type(scope)!: description.
+type ConventionalCommit struct {
+	Type        string
+	Scope       string
+	Breaking    bool // set by ! in the subject or a BREAKING CHANGE trailer
+	Description string
+}
+
+// Subject returns the first line of the commit message.
+func (c Commit) Subject() string {
+	msg := strings.TrimSpace(c.Message)
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		return strings.TrimSpace(msg[:i])
+	}
+	return msg
+}
+
+// Body returns the commit message without the subject and trailers.
+func (c Commit) Body() string {
+	paragraphs := messageParagraphs(c.Message)
+	if len(paragraphs) == 0 {
+		return ""
+	}
+	// the subject is the first line of the first paragraph
+	first := strings.SplitN(paragraphs[0], "\n", 2)
+	var body []string
+	if len(first) == 2 {
+		body = append(body, strings.TrimSpace(first[1]))
+	}
+	rest := paragraphs[1:]
+	if len(rest) > 0 {
+		if _, ok := parseTrailers(rest[len(rest)-1]); ok {
+			rest = rest[:len(rest)-1]
+		}
+	}
+	body = append(body, rest...)
+	return strings.TrimSpace(strings.Join(body, "\n\n"))
+}
+
+// Trailers returns the git trailers of the commit message in order.
+func (c Commit) Trailers() []Trailer {
+	paragraphs := messageParagraphs(c.Message)
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	trailers, _ := parseTrailers(paragraphs[len(paragraphs)-1])
+	return trailers
+}
+
+// Trailer returns the values of all trailers with key, compared case
+// insensitively, for example "Co-authored-by" or "Deploy-To".
+func (c Commit) Trailer(key string) []string {
+	var values []string
+	for _, t := range c.Trailers() {
+		if strings.EqualFold(t.Key, key) {
+			values = append(values, t.Value)
+		}
+	}
+	return values
+}
+
+// Skip reports whether the commit message contains a [skip name] or
+// [name skip] directive, compared case insensitively. Skip("notify")
+// matches "[skip notify]".
+func (c Commit) Skip(name string) bool {
+	msg := strings.ToLower(c.Message)
+	name = strings.ToLower(name)
+	return strings.Contains(msg, "[skip "+name+"]") || strings.Contains(msg, "["+name+" skip]")
+}
+
+// SkipCI reports whether the commit message contains [skip ci] or [ci skip]
+// which makes drone skip the build.
+func (c Commit) SkipCI() bool {
+	return c.Skip("ci")
+}
+
+var conventionalRe = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^()]*)\))?(!)?: (.+)$`)
+
+// Conventional parses the commit subject as a Conventional Commit. It
+// returns false if the subject does not follow the specification.
+func (c Commit) Conventional() (ConventionalCommit, bool) {
+	m := conventionalRe.FindStringSubmatch(c.Subject())
+	if m == nil {
+		return ConventionalCommit{}, false
+	}
+	cc := ConventionalCommit{
+		Type:        strings.ToLower(m[1]),
+		Scope:       m[2],
+		Breaking:    m[3] == "!",
+		Description: m[4],
+	}
+	if len(c.Trailer("BREAKING CHANGE")) > 0 || len(c.Trailer("BREAKING-CHANGE")) > 0 {
+		cc.Breaking = true
+	}
+	return cc, true
+}
+
+// messageParagraphs splits a commit message on blank lines.
+func messageParagraphs(msg string) []string {
+	msg = strings.Replace(msg, "\r\n", "\n", -1)
+	var (
+		paragraphs []string
+		cur        []string
+	)
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(cur) > 0 {
+				paragraphs = append(paragraphs, strings.Join(cur, "\n"))
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, line)
+	}
+	if len(cur) > 0 {
+		paragraphs = append(paragraphs, strings.Join(cur, "\n"))
+	}
+	return paragraphs
+}
+
+var trailerRe = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*|BREAKING CHANGE)(?:: | #)(.*)$`)
+
+// parseTrailers parses a paragraph which only consists of trailers and
+// their indented continuation lines.
+func parseTrailers(paragraph string) ([]Trailer, bool) {
+	var trailers []Trailer
+	for _, line := range strings.Split(paragraph, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(trailers) > 0 {
+			t := &trailers[len(trailers)-1]
+			t.Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		m := trailerRe.FindStringSubmatch(strings.TrimRight(line, " \t"))
+		if m == nil {
+			return nil, false
+		}
+		trailers = append(trailers, Trailer{Key: m[1], Value: strings.TrimSpace(m[2])})
+	}
+	return trailers, len(trailers) > 0
+}
@@ -0,0 +1,68 @@
+package plug_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+func TestCommitMessage(t *testing.T) {
+	c := plug.Commit{Message: `feat(api)!: add downstream trigger [skip notify]
+
+Triggers builds of other repositories
+after this one finished.
+
+Second paragraph.
+
+Signed-off-by: Octo Cat <octocat@example.com>
+Co-authored-by: Hubot <hubot@example.com>
+Deploy-To: production
+BREAKING CHANGE: the server option is
+  required now
+`}
+	if s := c.Subject(); s != "feat(api)!: add downstream trigger [skip notify]" {
+		t.Errorf("unexpected subject: %q", s)
+	}
+	if b := c.Body(); b != "Triggers builds of other repositories\nafter this one finished.\n\nSecond paragraph." {
+		t.Errorf("unexpected body: %q", b)
+	}
+	expected := []plug.Trailer{
+		{Key: "Signed-off-by", Value: "Octo Cat <octocat@example.com>"},
+		{Key: "Co-authored-by", Value: "Hubot <hubot@example.com>"},
+		{Key: "Deploy-To", Value: "production"},
+		{Key: "BREAKING CHANGE", Value: "the server option is required now"},
+	}
+	if tr := c.Trailers(); !reflect.DeepEqual(tr, expected) {
+		t.Errorf("unexpected trailers: %v", tr)
+	}
+	if v := c.Trailer("deploy-to"); !reflect.DeepEqual(v, []string{"production"}) {
+		t.Errorf("unexpected Deploy-To trailer: %v", v)
+	}
+	if !c.Skip("notify") || c.SkipCI() {
+		t.Error("unexpected skip directives")
+	}
+	cc, ok := c.Conventional()
+	if !ok || cc.Type != "feat" || cc.Scope != "api" || !cc.Breaking || cc.Description != "add downstream trigger [skip notify]" {
+		t.Errorf("unexpected conventional commit: %+v %v", cc, ok)
+	}
+}
+
+func TestCommitMessageSimple(t *testing.T) {
+	c := plug.Commit{Message: "Fix typo [CI SKIP]\n"}
+	if c.Subject() != "Fix typo [CI SKIP]" || c.Body() != "" || c.Trailers() != nil {
+		t.Errorf("unexpected parts: %q %q %v", c.Subject(), c.Body(), c.Trailers())
+	}
+	if !c.SkipCI() {
+		t.Error("expected [ci skip] to be detected")
+	}
+	if _, ok := c.Conventional(); ok {
+		t.Error("expected a non conventional commit")
+	}
+
+	// a body without a blank line after the subject
+	c = plug.Commit{Message: "Fix typo\nin the readme"}
+	if c.Subject() != "Fix typo" || c.Body() != "in the readme" {
+		t.Errorf("unexpected parts: %q %q", c.Subject(), c.Body())
+	}
+}
@@ -0,0 +1,199 @@
+package plug
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Constraint is a list of glob patterns a value has to match and must not
+// match, like a single field of a drone when condition.
+type Constraint struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+
+	globs map[string]*regexp.Regexp // path globs compiled by Set
+}
+
+// UnmarshalJSON accepts a single pattern, a list of patterns or an object
+// with include and exclude lists.
+func (c *Constraint) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*c = Constraint{Include: []string{s}}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*c = Constraint{Include: list}
+		return nil
+	}
+	var v struct {
+		Include json.RawMessage `json:"include"`
+		Exclude json.RawMessage `json:"exclude"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid condition: %s", data)
+	}
+	var include, exclude Constraint
+	if len(v.Include) > 0 {
+		if err := include.UnmarshalJSON(v.Include); err != nil {
+			return err
+		}
+	}
+	if len(v.Exclude) > 0 {
+		if err := exclude.UnmarshalJSON(v.Exclude); err != nil {
+			return err
+		}
+	}
+	*c = Constraint{Include: include.Include, Exclude: exclude.Include}
+	return nil
+}
+
+// IsEmpty reports whether c has no patterns.
+func (c Constraint) IsEmpty() bool {
+	return len(c.Include) == 0 && len(c.Exclude) == 0
+}
+
+// Match reports whether v matches c. A value matching an exclude pattern
+// never matches, an empty include list matches everything else.
+func (c Constraint) Match(v string) bool {
+	if c.Excludes(v) {
+		return false
+	}
+	if c.Includes(v) {
+		return true
+	}
+	return len(c.Include) == 0
+}
+
+// Includes reports whether v matches any include pattern.
+func (c Constraint) Includes(v string) bool {
+	return matchAny(c.Include, v)
+}
+
+// Excludes reports whether v matches any exclude pattern.
+func (c Constraint) Excludes(v string) bool {
+	return matchAny(c.Exclude, v)
+}
+
+func matchAny(patterns []string, v string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Condition mirrors the semantics of a drone when condition for plugins
+// which implement their own conditional behaviour, for example only
+// notifying on failures for some branches. It implements flag.Value and is
+// decoded from a JSON setting such as:
+//
+//	when:
+//	  branch: [ main, release/* ]
+//	  status:
+//	    include: [ failure ]
+type Condition struct {
+	Branch Constraint `json:"branch,omitempty"`
+	Event  Constraint `json:"event,omitempty"`
+	Ref    Constraint `json:"ref,omitempty"`
+	Repo   Constraint `json:"repo,omitempty"`
+	Status Constraint `json:"status,omitempty"`
+	Target Constraint `json:"target,omitempty"`
+}
+
+// ConditionVar defines a Condition flag with specified name and usage string.
+func (fs *FlagSet) ConditionVar(c *Condition, name, usage string) {
+	fs.Var(c, name, usage)
+}
+
+func (c *Condition) String() string {
+	data, err := json.Marshal(c)
+	if err != nil || string(data) == "{}" {
+		return ""
+	}
+	return string(data)
+}
+
+// Set implements flag.Value.
+func (c *Condition) Set(value string) error {
+	var v Condition
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// Match reports whether the build described by d matches all constraints.
+func (c Condition) Match(d Drone) bool {
+	ok, _ := c.Explain(d)
+	return ok
+}
+
+// MatchLog is like Match and also writes the explanation of the result to
+// log in debug mode.
+func (c Condition) MatchLog(d Drone, log *Logger) bool {
+	ok, reasons := c.Explain(d)
+	for _, r := range reasons {
+		log.Debugf("[condition] %s", r)
+	}
+	log.Debugf("[condition] matched: %v", ok)
+	return ok
+}
+
+// Explain reports whether the build described by d matches all constraints
+// and why. Like drone, the branch constraint is not applied to tag events
+// and an empty status constraint only matches successful builds.
+func (c Condition) Explain(d Drone) (bool, []string) {
+	var reasons []string
+	ok := true
+	check := func(name string, con Constraint, v string) {
+		if con.IsEmpty() {
+			return
+		}
+		switch {
+		case con.Excludes(v):
+			reasons = append(reasons, fmt.Sprintf("%s %q is excluded by %s", name, v, strings.Join(con.Exclude, ", ")))
+			ok = false
+		case con.Includes(v):
+			reasons = append(reasons, fmt.Sprintf("%s %q is included by %s", name, v, strings.Join(con.Include, ", ")))
+		case len(con.Include) > 0:
+			reasons = append(reasons, fmt.Sprintf("%s %q is not included by %s", name, v, strings.Join(con.Include, ", ")))
+			ok = false
+		default:
+			reasons = append(reasons, fmt.Sprintf("%s %q is not excluded", name, v))
+		}
+	}
+	if d.Build.IsTag() && !c.Branch.IsEmpty() {
+		reasons = append(reasons, "branch is not checked for tag events")
+	} else {
+		check("branch", c.Branch, d.Commit.Branch)
+	}
+	check("event", c.Event, d.Build.Event)
+	check("ref", c.Ref, d.Commit.Ref)
+	repo := ""
+	if d.Repo.Owner != "" || d.Repo.Name != "" {
+		repo = d.Repo.Owner + "/" + d.Repo.Name
+	}
+	check("repo", c.Repo, repo)
+	check("target", c.Target, d.Build.Deploy)
+
+	status := d.Build.Status
+	if status == "" {
+		status = string(StatusSuccess)
+	}
+	if c.Status.IsEmpty() {
+		if status != string(StatusSuccess) {
+			reasons = append(reasons, fmt.Sprintf("status %q does not match the default status success", status))
+			ok = false
+		}
+	} else {
+		check("status", c.Status, status)
+	}
+	return ok, reasons
+}
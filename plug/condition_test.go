@@ -0,0 +1,84 @@
+package plug_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type conditionPlugin struct {
+	When  plug.Condition
+	Drone plug.Drone
+}
+
+func (p *conditionPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.ConditionVar(&p.When, "when", "notify condition")
+	fs.DroneVar(&p.Drone)
+}
+
+func (p *conditionPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestCondition(t *testing.T) {
+	when := map[string]interface{}{
+		"branch": []string{"main", "release/*"},
+		"event":  map[string]interface{}{"exclude": "pull_request"},
+		"status": "failure",
+	}
+	tests := []struct {
+		drone plug.Drone
+		match bool
+	}{
+		{plug.Drone{
+			Build:  plug.Build{Event: "push", Status: "failure"},
+			Commit: plug.Commit{Branch: "main"},
+		}, true},
+		{plug.Drone{
+			Build:  plug.Build{Event: "push", Status: "failure"},
+			Commit: plug.Commit{Branch: "release/1.0"},
+		}, true},
+		{plug.Drone{
+			Build:  plug.Build{Event: "push", Status: "success"},
+			Commit: plug.Commit{Branch: "main"},
+		}, false},
+		{plug.Drone{
+			Build:  plug.Build{Event: "push", Status: "failure"},
+			Commit: plug.Commit{Branch: "feature/x"},
+		}, false},
+		{plug.Drone{
+			Build:  plug.Build{Event: "pull_request", Status: "failure"},
+			Commit: plug.Commit{Branch: "main"},
+		}, false},
+		{plug.Drone{
+			Build:  plug.Build{Event: "tag", Status: "failure"},
+			Commit: plug.Commit{Branch: "v1.0.0"},
+		}, true},
+	}
+	for i, tc := range tests {
+		p := &conditionPlugin{}
+		_, err := plug.Invoke(context.Background(), p, plug.Settings{"when": when}, plug.WithDrone(tc.drone))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, reasons := p.When.Explain(p.Drone); ok != tc.match {
+			t.Errorf("%d: expected match %v, got %v: %v", i, tc.match, ok, reasons)
+		}
+	}
+}
+
+func TestConditionDefaultStatus(t *testing.T) {
+	var c plug.Condition
+	if err := c.Set(`{"ref":{"include":["refs/tags/v*"]}}`); err != nil {
+		t.Fatal(err)
+	}
+	d := plug.Drone{Commit: plug.Commit{Ref: "refs/tags/v1.0.0"}}
+	if !c.Match(d) {
+		t.Error("expected match for unset status")
+	}
+	d.Build.Status = "failure"
+	if c.Match(d) {
+		t.Error("expected no match for failed build without status constraint")
+	}
+}
@@ -0,0 +1,95 @@
+package plug
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/go-pa/fenv"
+)
+
+// DefaultFunc registers fn to compute the default value of the option bound
+// to ref from the build metadata or other options. fn is called after all
+// env vars and flags are parsed if the option is not set and an empty
+// result leaves the option unset. The names of env vars and options the
+// value is computed from are shown in the usage output, from names the
+// sources read from the Drone field of Metadata which are not tracked.
+func (fs *FlagSet) DefaultFunc(ref interface{}, fn func(m Metadata) string, from ...string) {
+	fs.defaults = append(fs.defaults, defaultFunc{ref: ref, fn: fn, from: from})
+}
+
+// defaultFunc is registered by FlagSet.DefaultFunc.
+type defaultFunc struct {
+	ref  interface{}
+	fn   func(m Metadata) string
+	from []string
+}
+
+// parseDefaults sets unset options registered with FlagSet.DefaultFunc.
+// Returns false if a computed value was invalid.
+func (s *Service) parseDefaults(env map[string]string) bool {
+	if len(s.pfs.defaults) == 0 {
+		return true
+	}
+	drone := ParseDrone(env)
+	set := make(map[string]bool)
+	s.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	ok := true
+	for _, d := range s.pfs.defaults {
+		flg, err := s.findEnvFlag(d.ref)
+		if err != nil {
+			s.log.programmingFatalf("DefaultFunc: %v", err)
+		}
+		f := flg.Flag
+		if set[f.Name] || s.setBy[f.Name] != "" {
+			continue
+		}
+		var sources []string
+		value := d.fn(Metadata{Drone: drone, env: env, fs: s.fs, sources: &sources})
+		if value == "" {
+			continue
+		}
+		if err := s.fs.Set(f.Name, value); err != nil {
+			s.addUsageError(f.Name, fmt.Sprintf("invalid computed default %q: %v", value, err))
+			ok = false
+			continue
+		}
+		sources = dedupe(append(append([]string(nil), d.from...), sources...))
+		setBy := "default (computed)"
+		if len(sources) > 0 {
+			setBy = fmt.Sprintf("default (computed from %s)", strings.Join(sources, ", "))
+		}
+		s.log.Debugf("[default] '%s' %s: %s", f.Name, setBy, value)
+		s.setBy[f.Name] = setBy
+	}
+	return ok
+}
+
+// dedupe returns names without repeated names.
+func dedupe(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var out []string
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// ParseDrone returns the drone metadata from the DRONE_* variables in env
+// using the same bindings as FlagSet.DroneVar.
+func ParseDrone(env map[string]string) Drone {
+	var d Drone
+	fs := flag.NewFlagSet("drone", flag.ContinueOnError)
+	pfs := &FlagSet{
+		FlagSet: fs,
+		es:      fenv.NewEnvSet(fs, fenv.ContinueOnError()),
+	}
+	pfs.DroneVar(&d)
+	_ = pfs.es.ParseEnv(env)
+	return d
+}
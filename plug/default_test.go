@@ -0,0 +1,105 @@
+package plug_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type defaultPlugin struct {
+	Repo   string
+	Tag    string
+	Branch string
+	Image  string
+}
+
+func (p *defaultPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Repo, "repo", "", "image repository")
+	fs.DefaultFunc(&p.Repo, func(m plug.Metadata) string {
+		return m.Getenv("DRONE_REPO")
+	})
+	fs.StringVar(&p.Tag, "tag", "", "image tag")
+	fs.DefaultFunc(&p.Tag, func(m plug.Metadata) string {
+		if len(m.Commit.Sha) < 8 {
+			return ""
+		}
+		return m.Commit.Sha[:8]
+	}, "DRONE_COMMIT_SHA")
+	fs.StringVar(&p.Branch, "branch", "", "branch")
+	fs.DefaultFunc(&p.Branch, func(m plug.Metadata) string {
+		return m.CommitBranch()
+	}, "DRONE_COMMIT_BRANCH")
+	fs.StringVar(&p.Image, "image", "", "image name")
+	fs.DefaultFunc(&p.Image, func(m plug.Metadata) string {
+		if m.Getenv("DRONE_REPO") == "" {
+			return ""
+		}
+		return m.RepoFullName()
+	}, "DRONE_REPO")
+}
+
+func (p *defaultPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	if p.Repo == "" {
+		log.Usagef(&p.Repo, "required")
+		return plug.ErrUsageError
+	}
+	return nil
+}
+
+func TestDefaultFunc(t *testing.T) {
+	d := plug.Drone{
+		Repo:   plug.Repo{Owner: "octocat", Name: "hello"},
+		Commit: plug.Commit{Sha: "0123456789abcdef", Branch: "main"},
+	}
+	p := &defaultPlugin{}
+	_, err := plug.Invoke(context.Background(), p, plug.Settings{"branch": "release"}, plug.WithDrone(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Repo != "octocat/hello" || p.Tag != "01234567" || p.Branch != "release" {
+		t.Errorf("unexpected values: %+v", p)
+	}
+}
+
+func TestDefaultFuncUsage(t *testing.T) {
+	d := plug.Drone{Commit: plug.Commit{Sha: "0123456789abcdef"}}
+	res, err := plug.Invoke(context.Background(), &defaultPlugin{}, nil, plug.WithDrone(d))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(res.Log, "default (computed from DRONE_COMMIT_SHA)") {
+		t.Errorf("expected computed default in usage output:\n%s", res.Log)
+	}
+}
+
+func TestDefaultFuncSources(t *testing.T) {
+	d := plug.Drone{Repo: plug.Repo{Owner: "octocat", Name: "hello"}}
+	res, err := plug.Invoke(context.Background(), &defaultPlugin{}, plug.Settings{"plugin_debug": true}, plug.WithDrone(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Log, "'image' default (computed from DRONE_REPO): octocat/hello") {
+		t.Errorf("expected sources without duplicates in usage output:\n%s", res.Log)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	m := plug.Metadata{Drone: plug.Drone{
+		Repo:   plug.Repo{Owner: "octocat", Name: "hello"},
+		Commit: plug.Commit{Sha: "0123456789abcdef"},
+	}}
+	if v := m.Getenv("DRONE_REPO"); v != "octocat/hello" {
+		t.Errorf("Getenv: %q", v)
+	}
+	if v := m.RepoFullName(); v != "octocat/hello" {
+		t.Errorf("RepoFullName: %q", v)
+	}
+	if v := m.CommitSha(); v != "0123456789abcdef" {
+		t.Errorf("CommitSha: %q", v)
+	}
+	if v := m.Option("tag"); v != "" {
+		t.Errorf("Option: %q", v)
+	}
+}
@@ -1,6 +1,17 @@
 package plug
 
+import "strconv"
+
 type (
+	// Drone holds the build metadata drone passes to plugins as DRONE_*
+	// environment variables.
+	Drone struct {
+		Repo   Repo
+		Build  Build
+		Commit Commit
+		Stage  Stage
+		Step   Step
+	}
 	Repo struct {
 		Owner   string
 		Name    string
@@ -21,12 +32,33 @@ type (
 		Link     string
 	}
 	Commit struct {
-		Sha     string
-		Ref     string
-		Link    string
-		Branch  string
-		Message string
-		Author  Author
+		Sha          string
+		Before       string // sha before a push
+		Ref          string
+		Link         string
+		Branch       string
+		TargetBranch string // target branch of a pull request
+		Message      string
+		Author       Author
+	}
+	// Stage holds the pipeline stage metadata of drone 1.0 and later.
+	Stage struct {
+		Name     string
+		Number   int64
+		Kind     string
+		Type     string
+		Status   string
+		Started  int64
+		Finished int64
+		Machine  string
+		OS       string
+		Arch     string
+		Variant  string
+	}
+	// Step holds the pipeline step metadata of drone 1.0 and later.
+	Step struct {
+		Name   string
+		Number int64
 	}
 	Author struct {
 		Name   string
@@ -34,3 +66,71 @@ type (
 		Avatar string
 	}
 )
+
+// Env returns the metadata encoded as DRONE_* environment variables the same
+// way the FlagSet drone bindings read them. Empty values and numbers below
+// one, such as the -1 of unset numbers read by the bindings, are omitted.
+func (d Drone) Env() map[string]string {
+	env := map[string]string{"DRONE": "true"}
+	str := func(name, v string) {
+		if v != "" {
+			env[droneEnvName(name)] = v
+		}
+	}
+	num := func(name string, v int64) {
+		if v > 0 {
+			env[droneEnvName(name)] = strconv.FormatInt(v, 10)
+		}
+	}
+	boolean := func(name string, v bool) {
+		if v {
+			env[droneEnvName(name)] = "true"
+		}
+	}
+	str("repo.owner", d.Repo.Owner)
+	str("repo.name", d.Repo.Name)
+	if d.Repo.Owner != "" && d.Repo.Name != "" {
+		str("repo", d.Repo.Owner+"/"+d.Repo.Name)
+	}
+	str("repo.link", d.Repo.Link)
+	str("repo.avatar", d.Repo.Avatar)
+	str("repo.branch", d.Repo.Branch)
+	boolean("repo.private", d.Repo.Private)
+	boolean("repo.trusted", d.Repo.Trusted)
+
+	num("build.number", d.Build.Number)
+	str("build.event", d.Build.Event)
+	str("build.status", d.Build.Status)
+	str("deploy.to", d.Build.Deploy)
+	num("build.created", d.Build.Created)
+	num("build.started", d.Build.Started)
+	num("build.finished", d.Build.Finished)
+	str("build.link", d.Build.Link)
+
+	str("commit.sha", d.Commit.Sha)
+	str("commit.before", d.Commit.Before)
+	str("commit.ref", d.Commit.Ref)
+	str("commit.link", d.Commit.Link)
+	str("commit.branch", d.Commit.Branch)
+	str("target.branch", d.Commit.TargetBranch)
+	str("commit.message", d.Commit.Message)
+	str("commit.author.name", d.Commit.Author.Name)
+	str("commit.author.email", d.Commit.Author.Email)
+	str("commit.author.avatar", d.Commit.Author.Avatar)
+
+	str("stage.name", d.Stage.Name)
+	num("stage.number", d.Stage.Number)
+	str("stage.kind", d.Stage.Kind)
+	str("stage.type", d.Stage.Type)
+	str("stage.status", d.Stage.Status)
+	num("stage.started", d.Stage.Started)
+	num("stage.finished", d.Stage.Finished)
+	str("stage.machine", d.Stage.Machine)
+	str("stage.os", d.Stage.OS)
+	str("stage.arch", d.Stage.Arch)
+	str("stage.variant", d.Stage.Variant)
+
+	str("step.name", d.Step.Name)
+	num("step.number", d.Step.Number)
+	return env
+}
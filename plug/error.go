@@ -1,6 +1,7 @@
 package plug
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -23,14 +24,13 @@ var ErrUsageError = ExitError{
 // ExecError .
 type ExecError struct {
 	Err         error
-	UsageErrors map[string][]string
+	UsageErrors map[string][]string // usage errors by flag name, global usage errors use the empty key
 }
 
 func (e ExecError) Error() string {
 	var errs []string
 	if len(e.UsageErrors) > 0 {
 		errs = append(errs, fmt.Sprintf("%v usage errors", len(e.UsageErrors)))
-		return "usage errors"
 	}
 	if e.Err != nil {
 		errs = append(errs, e.Err.Error())
@@ -42,3 +42,64 @@ func (e ExecError) Error() string {
 
 	return strings.Join(errs, "; ")
 }
+
+// ExitCode returns the exit code of the process when the plugin is run with
+// Run. Usage errors registered by an Exec which returns nil do not fail the
+// run, the exit code is 0 then.
+func (e ExecError) ExitCode() int {
+	if e.Err == nil {
+		return 0
+	}
+	return exitCode(e.Err)
+}
+
+// ItemError is the error for a single item processed by ForEach.
+type ItemError struct {
+	Item string
+	Err  error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Item, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is returned by ForEach when one or more items failed.
+type MultiError struct {
+	Total  int          // number of items given to ForEach
+	Errors []*ItemError // errors in item order
+}
+
+func (e *MultiError) Error() string {
+	var errs []string
+	for _, err := range e.Errors {
+		errs = append(errs, err.Error())
+	}
+	return fmt.Sprintf("%v of %v items failed: %s", len(e.Errors), e.Total, strings.Join(errs, "; "))
+}
+
+// fmtErrorReport formats the failure summary printed when Exec returns an
+// error. Item errors from a MultiError are printed one per line.
+func fmtErrorReport(err error) string {
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		return fmt.Sprintf("plugin failed: %v", err)
+	}
+	lines := []string{fmt.Sprintf("plugin failed: %v of %v items failed", len(merr.Errors), merr.Total)}
+	for _, e := range merr.Errors {
+		lines = append(lines, fmt.Sprintf("  %s: %v", e.Item, e.Err))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// exitCode returns the process exit code for an error returned by Exec.
+func exitCode(err error) int {
+	var ee ExitError
+	if errors.As(err, &ee) && ee.ExitCode != 0 {
+		return ee.ExitCode
+	}
+	return 1
+}
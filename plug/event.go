@@ -0,0 +1,169 @@
+package plug
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event is a drone build event.
+type Event string
+
+// Build events.
+const (
+	EventPush        Event = "push"
+	EventPullRequest Event = "pull_request"
+	EventTag         Event = "tag"
+	EventPromote     Event = "promote"
+	EventRollback    Event = "rollback"
+	EventCron        Event = "cron"
+	EventCustom      Event = "custom"
+	EventDeployment  Event = "deployment" // drone 0.8 name for promote
+)
+
+var events = []Event{
+	EventPush, EventPullRequest, EventTag, EventPromote,
+	EventRollback, EventCron, EventCustom, EventDeployment,
+}
+
+// ParseEvent returns the Event for s or an error if s is not a known event.
+func ParseEvent(s string) (Event, error) {
+	for _, e := range events {
+		if string(e) == s {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("unknown build event: %s", s)
+}
+
+// Known reports whether e is one of the build events above.
+func (e Event) Known() bool {
+	_, err := ParseEvent(string(e))
+	return err == nil
+}
+
+func (e *Event) String() string {
+	return string(*e)
+}
+
+// Set implements flag.Value. Any value is accepted so plugins keep working
+// when drone adds new events, use Known to validate it.
+func (e *Event) Set(s string) error {
+	*e = Event(s)
+	return nil
+}
+
+// Status is a drone build or step status.
+type Status string
+
+// Build statuses.
+const (
+	StatusSkipped  Status = "skipped"
+	StatusBlocked  Status = "blocked"
+	StatusDeclined Status = "declined"
+	StatusWaiting  Status = "waiting_on_dependencies"
+	StatusPending  Status = "pending"
+	StatusRunning  Status = "running"
+	StatusSuccess  Status = "success"
+	StatusFailure  Status = "failure"
+	StatusKilled   Status = "killed"
+	StatusError    Status = "error"
+)
+
+var statuses = []Status{
+	StatusSkipped, StatusBlocked, StatusDeclined, StatusWaiting, StatusPending,
+	StatusRunning, StatusSuccess, StatusFailure, StatusKilled, StatusError,
+}
+
+// ParseStatus returns the Status for s or an error if s is not a known status.
+func ParseStatus(s string) (Status, error) {
+	for _, st := range statuses {
+		if string(st) == s {
+			return st, nil
+		}
+	}
+	return "", fmt.Errorf("unknown build status: %s", s)
+}
+
+// Known reports whether s is one of the build statuses above.
+func (s Status) Known() bool {
+	_, err := ParseStatus(string(s))
+	return err == nil
+}
+
+func (s *Status) String() string {
+	return string(*s)
+}
+
+// Set implements flag.Value. Any value is accepted so plugins keep working
+// when drone adds new statuses, use Known to validate it.
+func (s *Status) Set(v string) error {
+	*s = Status(v)
+	return nil
+}
+
+// EventType returns Event as an Event.
+func (b Build) EventType() Event { return Event(b.Event) }
+
+// StatusType returns Status as a Status.
+func (b Build) StatusType() Status { return Status(b.Status) }
+
+// StatusType returns Status as a Status.
+func (s Stage) StatusType() Status { return Status(s.Status) }
+
+// IsPush reports whether the build was triggered by a push.
+func (b Build) IsPush() bool { return b.EventType() == EventPush }
+
+// IsPullRequest reports whether the build was triggered by a pull request.
+func (b Build) IsPullRequest() bool { return b.EventType() == EventPullRequest }
+
+// IsTag reports whether the build was triggered by a tag.
+func (b Build) IsTag() bool { return b.EventType() == EventTag }
+
+// IsPromotion reports whether the build is a promotion, including drone 0.8 deployments.
+func (b Build) IsPromotion() bool {
+	return b.EventType() == EventPromote || b.EventType() == EventDeployment
+}
+
+// IsRollback reports whether the build is a rollback.
+func (b Build) IsRollback() bool { return b.EventType() == EventRollback }
+
+// IsCron reports whether the build was triggered by a cron job.
+func (b Build) IsCron() bool { return b.EventType() == EventCron }
+
+// IsCustom reports whether the build was triggered manually or by the API.
+func (b Build) IsCustom() bool { return b.EventType() == EventCustom }
+
+// IsSuccess reports whether the build status is success.
+func (b Build) IsSuccess() bool { return b.StatusType() == StatusSuccess }
+
+// IsFailure reports whether the build status is failure.
+func (b Build) IsFailure() bool { return b.StatusType() == StatusFailure }
+
+// CreatedTime returns Created as a time.Time, the zero time if it is not set.
+func (b Build) CreatedTime() time.Time { return unixTime(b.Created) }
+
+// StartedTime returns Started as a time.Time, the zero time if it is not set.
+func (b Build) StartedTime() time.Time { return unixTime(b.Started) }
+
+// FinishedTime returns Finished as a time.Time, the zero time if it is not set.
+func (b Build) FinishedTime() time.Time { return unixTime(b.Finished) }
+
+// Duration returns the time from the build start until it finished, or until
+// now if it is still running. Zero is returned if the build has not started.
+func (b Build) Duration() time.Duration {
+	if b.Started <= 0 {
+		return 0
+	}
+	end := time.Now()
+	if b.Finished > 0 {
+		end = b.FinishedTime()
+	}
+	return end.Sub(b.StartedTime())
+}
+
+func unixTime(v int64) time.Time {
+	if v <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(v, 0)
+}
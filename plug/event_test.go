@@ -0,0 +1,70 @@
+package plug_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type buildPlugin struct {
+	Build plug.Build
+}
+
+func (p *buildPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.BuildVar(&p.Build)
+}
+
+func (p *buildPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestBuildEvent(t *testing.T) {
+	p := &buildPlugin{}
+	_, err := plug.Invoke(context.Background(), p, nil, plug.WithDrone(plug.Drone{
+		Build: plug.Build{
+			Event:    "tag",
+			Status:   "success",
+			Started:  1500000000,
+			Finished: 1500000090,
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := p.Build
+	if !b.IsTag() || b.IsPush() || !b.IsSuccess() {
+		t.Errorf("unexpected predicates for %+v", b)
+	}
+	if b.Duration() != 90*time.Second {
+		t.Errorf("unexpected duration: %v", b.Duration())
+	}
+	if !b.CreatedTime().IsZero() || b.StartedTime().Unix() != 1500000000 {
+		t.Errorf("unexpected times: %v %v", b.CreatedTime(), b.StartedTime())
+	}
+}
+
+func TestBuildEventUnknown(t *testing.T) {
+	p := &buildPlugin{}
+	_, err := plug.Invoke(context.Background(), p, nil, plug.WithEnv(map[string]string{
+		"DRONE_BUILD_EVENT":  "merge_group",
+		"DRONE_BUILD_STATUS": "done",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Build.Event != "merge_group" || p.Build.EventType().Known() || p.Build.StatusType().Known() {
+		t.Errorf("unexpected build: %+v", p.Build)
+	}
+	if _, err := plug.ParseEvent("pull_request"); err != nil {
+		t.Error(err)
+	}
+	if _, err := plug.ParseStatus("done"); err == nil {
+		t.Error("expected an error for an unknown status")
+	}
+	var e plug.Event
+	if err := e.Set("merge_group"); err != nil || e.Known() {
+		t.Errorf("unexpected event %q: %v", e, err)
+	}
+}
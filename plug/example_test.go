@@ -108,9 +108,11 @@ func Example_plugin() {
 	//  },
 	//  "Commit": {
 	//   "Sha": "",
+	//   "Before": "",
 	//   "Ref": "",
 	//   "Link": "",
 	//   "Branch": "",
+	//   "TargetBranch": "",
 	//   "Message": "Added text",
 	//   "Author": {
 	//    "Name": "",
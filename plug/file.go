@@ -0,0 +1,112 @@
+package plug
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// MaxSettingFileSize is the max size of a file read for an option.
+var MaxSettingFileSize int64 = 1 << 20
+
+// DisallowFile stops the options bound to refs from being read from a file.
+// By default every option except the drone metadata can be read from a
+// file, which is useful for secrets mounted as files. For an option named
+// token the path is given by the token-file flag or the PLUGIN_TOKEN_FILE env
+// var. Trailing newlines are trimmed from the file content and setting both
+// the option and its file is an usage error. If no refs are given no option
+// can be read from a file.
+func (fs *FlagSet) DisallowFile(refs ...interface{}) {
+	if len(refs) == 0 {
+		fs.disallowFileAll = true
+		return
+	}
+	fs.disallowFile = append(fs.disallowFile, refs...)
+}
+
+// registerFileFlags defines the -name-file flags for all options which are
+// not disallowed by FlagSet.DisallowFile.
+func (s *Service) registerFileFlags() {
+	if s.pfs.disallowFileAll {
+		return
+	}
+	disallowed := make(map[string]bool)
+	for _, ref := range s.pfs.disallowFile {
+		flg, err := s.findEnvFlag(ref)
+		if err != nil {
+			s.log.programmingFatalf("DisallowFile: %v", err)
+		}
+		disallowed[flg.Flag.Name] = true
+	}
+	var flags []*flag.Flag
+	s.fs.VisitAll(func(f *flag.Flag) {
+		if !disallowed[f.Name] && !s.pfs.droneFlags[f.Name] && f.Name != envfileFlagName {
+			flags = append(flags, f)
+		}
+	})
+	for _, f := range flags {
+		name := f.Name + "-file"
+		if s.fs.Lookup(name) != nil {
+			continue
+		}
+		v := new(string)
+		s.fs.StringVar(v, name, "", fmt.Sprintf("read %s from file", f.Name))
+		s.fileFlags[name] = f.Name
+	}
+}
+
+// parseFiles sets options from the files given by their -name-file flags. It
+// must be called after all flags have been parsed. Returns false if there
+// were conflicting settings or files could not be read.
+func (s *Service) parseFiles() bool {
+	ok := true
+	set := make(map[string]bool)
+	s.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for fileName, name := range s.fileFlags {
+		if !set[fileName] {
+			continue
+		}
+		filename := s.fs.Lookup(fileName).Value.String()
+		if set[name] || s.setBy[name] != "" {
+			s.addUsageError(name, fmt.Sprintf("%s and %s can not both be set", name, fileName))
+			ok = false
+			continue
+		}
+		value, err := readSettingFile(filename)
+		if err != nil {
+			s.addUsageError(name, err.Error())
+			ok = false
+			continue
+		}
+		if err := s.fs.Set(name, value); err != nil {
+			s.addUsageError(name, fmt.Sprintf("invalid value in file %s: %v", filename, err))
+			ok = false
+			continue
+		}
+		s.log.Debugf("[file] '%s' set from file %s", name, filename)
+		s.setBy[name] = "file " + filename
+	}
+	return ok
+}
+
+// readSettingFile reads a setting value from filename.
+func readSettingFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(f, MaxSettingFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > MaxSettingFileSize {
+		return "", fmt.Errorf("file %s is larger than %d bytes", filename, MaxSettingFileSize)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,141 @@
+package plug_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type filePlugin struct {
+	Token string
+}
+
+func (p *filePlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Token, "token", "", "api token")
+}
+
+func (p *filePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "plug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filename := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestFile(t *testing.T) {
+	filename := writeTempFile(t, "s3cr3t\n")
+	p := &filePlugin{}
+	_, err := plug.Invoke(context.Background(), p, plug.Settings{"token_file": filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Token != "s3cr3t" {
+		t.Errorf("expected token from file, got %q", p.Token)
+	}
+
+	p = &filePlugin{}
+	_, err = plug.Invoke(context.Background(), p, nil, plug.WithArgs("-token-file", filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Token != "s3cr3t" {
+		t.Errorf("expected token from -token-file, got %q", p.Token)
+	}
+}
+
+func TestFileConflict(t *testing.T) {
+	filename := writeTempFile(t, "s3cr3t")
+	res, err := plug.Invoke(context.Background(), &filePlugin{}, plug.Settings{
+		"token":      "other",
+		"token_file": filename,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if errs := err.(*plug.ExecError).UsageErrors["token"]; len(errs) != 1 {
+		t.Errorf("expected an usage error for token, got %v", errs)
+	}
+	if strings.Contains(res.Log, "s3cr3t") {
+		t.Errorf("file content should not be printed:\n%s", res.Log)
+	}
+}
+
+type configFilePlugin struct {
+	Config     string
+	ConfigFile string
+	Token      string
+	Name       string
+}
+
+func (p *configFilePlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Config, "config", "", "config")
+	fs.StringVar(&p.ConfigFile, "config-file", "", "config file")
+	fs.StringVar(&p.Token, "token", "", "api token")
+	fs.StringVar(&p.Name, "name", "", "name")
+	fs.DisallowFile(&p.Name)
+	fs.BuildNumberVar(new(int64))
+}
+
+func (p *configFilePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestFileOptions(t *testing.T) {
+	fileFor := make(map[string]string)
+	for _, o := range plug.Options(&configFilePlugin{}) {
+		fileFor[o.Name] = o.FileFor
+	}
+	expected := map[string]string{
+		"config":           "",
+		"config-file":      "",
+		"config-file-file": "config-file",
+		"token":            "",
+		"token-file":       "token",
+		"name":             "",
+	}
+	for name, v := range expected {
+		if got, ok := fileFor[name]; !ok || got != v {
+			t.Errorf("%s: expected FileFor %q, got %q", name, v, got)
+		}
+	}
+	for _, name := range []string{"name-file", "build.number-file", "env_file-file"} {
+		if _, ok := fileFor[name]; ok {
+			t.Errorf("%s should not be registered", name)
+		}
+	}
+}
+
+type noFilePlugin struct {
+	Token string
+}
+
+func (p *noFilePlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Token, "token", "", "api token")
+	fs.DisallowFile()
+}
+
+func (p *noFilePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestDisallowFile(t *testing.T) {
+	for _, o := range plug.Options(&noFilePlugin{}) {
+		if o.Name != "token" {
+			t.Errorf("unexpected option %s", o.Name)
+		}
+	}
+}
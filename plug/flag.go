@@ -12,9 +12,24 @@ import (
 // FlagSet adds drone plugin specific functionality to a wrapped flag.FlagSet
 type FlagSet struct {
 	*flag.FlagSet
-	es             *fenv.EnvSet
-	envFiles       []string
-	envFilesActive bool
+	es              *fenv.EnvSet
+	envFiles        []string
+	envFilesActive  bool
+	deprecated      []deprecation
+	aliases         []alias
+	disallowFile    []interface{}   // refs given to DisallowFile
+	disallowFileAll bool            // DisallowFile was called without refs
+	droneFlags      map[string]bool // names of flags defined by droneFlag
+	interpolate     []interface{}   // refs given to Interpolate
+	defaults        []defaultFunc   // registered by DefaultFunc
+	gitFallback     bool            // GitFallback was called
+	paths           *Constraint     // bound by PathsVar
+}
+
+// deprecation is registered by FlagSet.Deprecated.
+type deprecation struct {
+	ref interface{}
+	msg string
 }
 
 // FlagEnv replaces automatically generated environment variable names
@@ -27,6 +42,12 @@ func (fs *FlagSet) Env(flagVar interface{}, envName ...string) {
 	fs.es.Var(flagVar, envName...)
 }
 
+// Deprecated marks the option bound to ref as deprecated. A warning with msg
+// is registered when the option is set.
+func (fs *FlagSet) Deprecated(ref interface{}, msg string) {
+	fs.deprecated = append(fs.deprecated, deprecation{ref: ref, msg: msg})
+}
+
 func (fs *FlagSet) StringSliceVar(value *[]string, name, usage string) {
 	fs.Var((*stringSliceFlag)(value), name, usage)
 }
@@ -60,9 +81,11 @@ func (fs *FlagSet) RepoVar(r *Repo) {
 func (fs *FlagSet) CommitVar(c *Commit) {
 	fs.CommitMessageVar(&c.Message)
 	fs.CommitShaVar(&c.Sha)
+	fs.CommitBeforeVar(&c.Before)
 	fs.CommitRefVar(&c.Ref)
 	fs.CommitLinkVar(&c.Link)
 	fs.CommitBranchVar(&c.Branch)
+	fs.TargetBranchVar(&c.TargetBranch)
 	fs.CommitAuthorEmailVar(&c.Author.Email)
 	fs.CommitAuthorNameVar(&c.Author.Name)
 	fs.CommitAuthorAvatarVar(&c.Author.Avatar)
@@ -79,6 +102,34 @@ func (fs *FlagSet) BuildVar(b *Build) {
 	fs.BuildLinkVar(&b.Link)
 }
 
+func (fs *FlagSet) StageVar(s *Stage) {
+	fs.StageNameVar(&s.Name)
+	fs.StageNumberVar(&s.Number)
+	fs.StageKindVar(&s.Kind)
+	fs.StageTypeVar(&s.Type)
+	fs.StageStatusVar(&s.Status)
+	fs.StageStartedVar(&s.Started)
+	fs.StageFinishedVar(&s.Finished)
+	fs.StageMachineVar(&s.Machine)
+	fs.StageOSVar(&s.OS)
+	fs.StageArchVar(&s.Arch)
+	fs.StageVariantVar(&s.Variant)
+}
+
+func (fs *FlagSet) StepVar(s *Step) {
+	fs.StepNameVar(&s.Name)
+	fs.StepNumberVar(&s.Number)
+}
+
+// DroneVar registers all fields for Repo, Build, Commit, Stage and Step.
+func (fs *FlagSet) DroneVar(d *Drone) {
+	fs.RepoVar(&d.Repo)
+	fs.BuildVar(&d.Build)
+	fs.CommitVar(&d.Commit)
+	fs.StageVar(&d.Stage)
+	fs.StepVar(&d.Step)
+}
+
 // RepoFullNameVar defines a string flag for DRONE_REPO.
 func (fs *FlagSet) RepoFullNameVar(v *string) {
 	fs.droneFlag("repo", v, "repository full name")
@@ -134,11 +185,21 @@ func (fs *FlagSet) BuildEventVar(v *string) {
 	fs.droneFlag("build.event", v, "build event (push, pull_request, tag)")
 }
 
+// EventVar defines an Event flag for DRONE_BUILD_EVENT
+func (fs *FlagSet) EventVar(v *Event) {
+	fs.droneFlag("build.event", v, "build event (push, pull_request, tag)")
+}
+
 // BuildStatusVar defines a string flag for DRONE_BUILD_STATUS
 func (fs *FlagSet) BuildStatusVar(v *string) {
 	fs.droneFlag("build.status", v, "build status (success, failure)")
 }
 
+// StatusVar defines a Status flag for DRONE_BUILD_STATUS
+func (fs *FlagSet) StatusVar(v *Status) {
+	fs.droneFlag("build.status", v, "build status (success, failure)")
+}
+
 // BuildCreatedVar defines a int flag for DRONE_BUILD_CREATED
 func (fs *FlagSet) BuildCreatedVar(v *int64) {
 	fs.droneFlag("build.created", v, "build created unix timestamp")
@@ -164,6 +225,11 @@ func (fs *FlagSet) CommitShaVar(v *string) {
 	fs.droneFlag("commit.sha", v, "commit sha")
 }
 
+// CommitBeforeVar defines a string flag for DRONE_COMMIT_BEFORE
+func (fs *FlagSet) CommitBeforeVar(v *string) {
+	fs.droneFlag("commit.before", v, "commit sha before the push")
+}
+
 // CommitRefVar defines a string flag for DRONE_COMMIT_REF
 func (fs *FlagSet) CommitRefVar(v *string) {
 	fs.droneFlag("commit.ref", v, "commit ref")
@@ -179,6 +245,11 @@ func (fs *FlagSet) CommitBranchVar(v *string) {
 	fs.droneFlag("commit.branch", v, "commit branch")
 }
 
+// TargetBranchVar defines a string flag for DRONE_TARGET_BRANCH
+func (fs *FlagSet) TargetBranchVar(v *string) {
+	fs.droneFlag("target.branch", v, "pull request target branch")
+}
+
 // CommitMessageVar defines a string flag for DRONE_COMMIT_MESSAGE
 func (fs *FlagSet) CommitMessageVar(v *string) {
 	fs.droneFlag("commit.message", v, "commit message")
@@ -224,16 +295,78 @@ func (fs *FlagSet) DronePullRequestVar(v *string) {
 	fs.droneFlag("pull.request", v, "pull request number")
 }
 
+// StageNameVar defines a string flag for DRONE_STAGE_NAME
+func (fs *FlagSet) StageNameVar(v *string) {
+	fs.droneFlag("stage.name", v, "stage name")
+}
+
+// StageNumberVar defines a int flag for DRONE_STAGE_NUMBER
+func (fs *FlagSet) StageNumberVar(v *int64) {
+	fs.droneFlag("stage.number", v, "stage number")
+}
+
+// StageKindVar defines a string flag for DRONE_STAGE_KIND
+func (fs *FlagSet) StageKindVar(v *string) {
+	fs.droneFlag("stage.kind", v, "stage kind (pipeline)")
+}
+
+// StageTypeVar defines a string flag for DRONE_STAGE_TYPE
+func (fs *FlagSet) StageTypeVar(v *string) {
+	fs.droneFlag("stage.type", v, "stage type (docker, exec, kubernetes)")
+}
+
+// StageStatusVar defines a string flag for DRONE_STAGE_STATUS
+func (fs *FlagSet) StageStatusVar(v *string) {
+	fs.droneFlag("stage.status", v, "stage status (success, failure)")
+}
+
+// StageStartedVar defines a int flag for DRONE_STAGE_STARTED
+func (fs *FlagSet) StageStartedVar(v *int64) {
+	fs.droneFlag("stage.started", v, "stage started unix timestamp")
+}
+
+// StageFinishedVar defines a int flag for DRONE_STAGE_FINISHED
+func (fs *FlagSet) StageFinishedVar(v *int64) {
+	fs.droneFlag("stage.finished", v, "stage finished unix timestamp")
+}
+
+// StageMachineVar defines a string flag for DRONE_STAGE_MACHINE
+func (fs *FlagSet) StageMachineVar(v *string) {
+	fs.droneFlag("stage.machine", v, "stage runner machine name")
+}
+
+// StageOSVar defines a string flag for DRONE_STAGE_OS
+func (fs *FlagSet) StageOSVar(v *string) {
+	fs.droneFlag("stage.os", v, "stage operating system (linux, windows)")
+}
+
+// StageArchVar defines a string flag for DRONE_STAGE_ARCH
+func (fs *FlagSet) StageArchVar(v *string) {
+	fs.droneFlag("stage.arch", v, "stage architecture (amd64, arm64)")
+}
+
+// StageVariantVar defines a string flag for DRONE_STAGE_VARIANT
+func (fs *FlagSet) StageVariantVar(v *string) {
+	fs.droneFlag("stage.variant", v, "stage architecture variant")
+}
+
+// StepNameVar defines a string flag for DRONE_STEP_NAME
+func (fs *FlagSet) StepNameVar(v *string) {
+	fs.droneFlag("step.name", v, "step name")
+}
+
+// StepNumberVar defines a int flag for DRONE_STEP_NUMBER
+func (fs *FlagSet) StepNumberVar(v *int64) {
+	fs.droneFlag("step.number", v, "step number")
+}
+
 var (
 	flagNamePrefix = "" // for tests
 )
 
 func (fs *FlagSet) droneFlag(name string, ref interface{}, help string) {
 	name = flagNamePrefix + name
-	s := "drone_" + name
-	s = strings.Replace(s, ".", "_", -1)
-	s = strings.Replace(s, "-", "_", -1)
-	s = strings.ToUpper(s)
+	s := droneEnvName(name)
 	usage := fmt.Sprintf("%s (%s)", help, s)
 	switch v := ref.(type) {
 	case *string:
@@ -242,12 +375,26 @@ func (fs *FlagSet) droneFlag(name string, ref interface{}, help string) {
 		fs.BoolVar(v, name, false, usage)
 	case *int64:
 		fs.Int64Var(v, name, -1, usage)
+	case flag.Value:
+		fs.Var(v, name, usage)
 	default:
 		panic(v)
 	}
+	if fs.droneFlags == nil {
+		fs.droneFlags = make(map[string]bool)
+	}
+	fs.droneFlags[name] = true
 	fs.Env(ref, s)
 }
 
+// droneEnvName returns the DRONE_* environment variable name for a drone flag name.
+func droneEnvName(name string) string {
+	s := "drone_" + name
+	s = strings.Replace(s, ".", "_", -1)
+	s = strings.Replace(s, "-", "_", -1)
+	return strings.ToUpper(s)
+}
+
 // stringSliceFlag is a flag type which
 type stringSliceFlag []string
 
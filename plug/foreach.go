@@ -0,0 +1,86 @@
+package plug
+
+import (
+	"context"
+	"sync"
+)
+
+// Concurrency configures how ForEach processes items.
+type Concurrency struct {
+	Limit    int  // max number of items processed in parallel, values below 1 means 1
+	FailFast bool // stop starting new items after the first error
+}
+
+// ConcurrencyVar defines the concurrency and fail_fast flags for c. Values
+// already set in c are used as defaults.
+func (fs *FlagSet) ConcurrencyVar(c *Concurrency) {
+	limit := c.Limit
+	if limit < 1 {
+		limit = 1
+	}
+	fs.IntVar(&c.Limit, "concurrency", limit, "max number of items processed in parallel")
+	fs.BoolVar(&c.FailFast, "fail_fast", c.FailFast, "stop processing items after the first error")
+}
+
+// ItemFunc is called by ForEach for each item with a child logger for that item.
+type ItemFunc func(ctx context.Context, log *Logger, item string) error
+
+// ForEach calls fn for each item with at most c.Limit calls running in
+// parallel. All items are processed unless c.FailFast is set, in which case
+// ctx passed to running calls is canceled and no new items are started after
+// the first error. Failed items are returned as a *MultiError which is
+// reported item by item when returned from Exec.
+func ForEach(ctx context.Context, log *Logger, items []string, c Concurrency, fn ItemFunc) error {
+	limit := c.Limit
+	if limit < 1 {
+		limit = 1
+	}
+	ictx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	started := 0
+loop:
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ictx.Done():
+			break loop
+		}
+		if ictx.Err() != nil {
+			<-sem
+			break loop
+		}
+		started++
+		wg.Add(1)
+		go func(i int, item string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(ictx, log.With("item", item), item); err != nil {
+				errs[i] = err
+				if c.FailFast {
+					cancel()
+				}
+			}
+		}(i, item)
+	}
+	wg.Wait()
+	if started < len(items) {
+		log.Debugf("[foreach] skipped %d of %d items", len(items)-started, len(items))
+	}
+
+	merr := &MultiError{Total: len(items)}
+	for i, err := range errs {
+		if err != nil {
+			merr.Errors = append(merr.Errors, &ItemError{Item: items[i], Err: err})
+		}
+	}
+	if len(merr.Errors) > 0 {
+		return merr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,78 @@
+package plug_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type forEachPlugin struct {
+	Repos       []string
+	Concurrency plug.Concurrency
+	running     int32
+	maxRunning  int32
+	calls       int32
+}
+
+func (p *forEachPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringSliceVar(&p.Repos, "repositories", "repositories to trigger")
+	fs.ConcurrencyVar(&p.Concurrency)
+}
+
+func (p *forEachPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return plug.ForEach(ctx, log, p.Repos, p.Concurrency, func(ctx context.Context, log *plug.Logger, repo string) error {
+		atomic.AddInt32(&p.calls, 1)
+		n := atomic.AddInt32(&p.running, 1)
+		defer atomic.AddInt32(&p.running, -1)
+		for {
+			max := atomic.LoadInt32(&p.maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&p.maxRunning, max, n) {
+				break
+			}
+		}
+		if strings.HasPrefix(repo, "bad/") {
+			return errors.New("not found")
+		}
+		return nil
+	})
+}
+
+func TestForEachCollectAll(t *testing.T) {
+	p := &forEachPlugin{}
+	res, err := plug.Invoke(context.Background(), p, plug.Settings{
+		"repositories": []string{"ok/1", "bad/2", "ok/3", "bad/4"},
+		"concurrency":  2,
+	})
+	var merr *plug.MultiError
+	if !errors.As(err.(*plug.ExecError).Err, &merr) {
+		t.Fatalf("expected *plug.MultiError, got %v", err)
+	}
+	if len(merr.Errors) != 2 || merr.Errors[0].Item != "bad/2" || merr.Errors[1].Item != "bad/4" {
+		t.Errorf("unexpected item errors: %v", merr)
+	}
+	if p.calls != 4 || p.maxRunning > 2 {
+		t.Errorf("expected 4 calls with at most 2 in parallel, got %d calls and %d in parallel", p.calls, p.maxRunning)
+	}
+	expected := "plugin failed: 2 of 4 items failed\n  bad/2: not found\n  bad/4: not found\n"
+	if res.Log != expected {
+		t.Errorf("unexpected report:\n%s", res.Log)
+	}
+}
+
+func TestForEachFailFast(t *testing.T) {
+	p := &forEachPlugin{}
+	_, err := plug.Invoke(context.Background(), p, plug.Settings{
+		"repositories": []string{"bad/1", "ok/2", "ok/3"},
+		"fail_fast":    true,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if p.calls != 1 {
+		t.Errorf("expected processing to stop after the first item, got %d calls", p.calls)
+	}
+}
@@ -0,0 +1,171 @@
+package plug
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GitFallback enables filling unset repo and commit options bound with
+// RepoVar, CommitVar and the related methods from the git repository in
+// DRONE_WORKSPACE, or the working directory if it is not set, when the plugin
+// does not run in drone. This makes local
+// runs behave like runs in CI. The usage output shows such options as set
+// by git.
+func (fs *FlagSet) GitFallback() {
+	fs.gitFallback = true
+}
+
+// GitInfo is the metadata read from a local git repository.
+type GitInfo struct {
+	Repo      Repo
+	Commit    Commit
+	RemoteURL string   // url of the origin remote
+	Tags      []string // tags pointing at HEAD
+}
+
+// ReadGit reads the metadata of the git repository containing dir using the
+// git command. Git is run by the Commander of the service log belongs to,
+// log may be nil to run it with os/exec.
+func ReadGit(ctx context.Context, log *Logger, dir string) (GitInfo, error) {
+	var info GitInfo
+	run := func(args ...string) (string, error) {
+		return gitOutput(ctx, log, dir, args...)
+	}
+
+	sha, err := run("rev-parse", "HEAD")
+	if err != nil {
+		return info, err
+	}
+	info.Commit.Sha = sha
+	commit, err := run("log", "-1", "--format=%an%x00%ae%x00%B")
+	if err != nil {
+		return info, err
+	}
+	if parts := strings.SplitN(commit, "\x00", 3); len(parts) == 3 {
+		info.Commit.Author.Name = parts[0]
+		info.Commit.Author.Email = parts[1]
+		info.Commit.Message = strings.TrimRight(parts[2], "\n")
+	}
+	if tags, err := run("tag", "--points-at", "HEAD"); err == nil && tags != "" {
+		info.Tags = strings.Split(tags, "\n")
+	}
+	// symbolic-ref fails with a detached HEAD
+	if branch, err := run("symbolic-ref", "--short", "-q", "HEAD"); err == nil && branch != "" {
+		info.Commit.Branch = branch
+		info.Commit.Ref = "refs/heads/" + branch
+	} else if len(info.Tags) > 0 {
+		info.Commit.Ref = "refs/tags/" + info.Tags[0]
+	}
+	if remote, err := run("config", "--get", "remote.origin.url"); err == nil && remote != "" {
+		info.RemoteURL = remote
+		info.Repo.Owner, info.Repo.Name, info.Repo.Link = parseRemoteURL(remote)
+	}
+	return info, nil
+}
+
+// gitOutput runs git with args in dir and returns its output without the
+// trailing newline.
+func gitOutput(ctx context.Context, log *Logger, dir string, args ...string) (string, error) {
+	cmd := &Cmd{Args: append([]string{"git"}, args...), Dir: dir, log: log}
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		var cerr *CommandError
+		if errors.As(err, &cerr) {
+			return "", err
+		}
+		return "", fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// parseRemoteURL returns the owner, name and web link of a repository from
+// its clone url, such as git@github.com:octocat/hello-world.git or
+// https://github.com/octocat/hello-world.git.
+func parseRemoteURL(remote string) (owner, name, link string) {
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+		if u.Scheme == "http" || u.Scheme == "https" {
+			host = u.Host
+		}
+	} else if i := strings.Index(remote, ":"); i > 0 && !strings.Contains(remote[:i], "/") {
+		// scp like syntax
+		host, path = remote[:i], remote[i+1:]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+	} else {
+		return "", "", ""
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return "", path, ""
+	}
+	owner, name = path[:i], path[i+1:]
+	return owner, name, "https://" + host + "/" + path
+}
+
+// values returns the values of info by drone flag name.
+func (info GitInfo) values() map[string]string {
+	values := map[string]string{
+		"repo.owner":          info.Repo.Owner,
+		"repo.name":           info.Repo.Name,
+		"repo.link":           info.Repo.Link,
+		"remote.url":          info.RemoteURL,
+		"commit.sha":          info.Commit.Sha,
+		"commit.ref":          info.Commit.Ref,
+		"commit.branch":       info.Commit.Branch,
+		"commit.message":      info.Commit.Message,
+		"commit.author.name":  info.Commit.Author.Name,
+		"commit.author.email": info.Commit.Author.Email,
+	}
+	if info.Repo.Owner != "" || info.Repo.Name != "" {
+		values["repo"] = info.Repo.Owner + "/" + info.Repo.Name
+	}
+	return values
+}
+
+// parseGit sets unset drone flags from the local git repository when
+// enabled with FlagSet.GitFallback and not running in drone. Failing to read
+// the repository only logs a debug message.
+func (s *Service) parseGit(ctx context.Context, env map[string]string) {
+	if !s.pfs.gitFallback || s.asPlugin {
+		return
+	}
+	set := make(map[string]bool)
+	s.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var unset []string
+	s.fs.VisitAll(func(f *flag.Flag) {
+		if s.pfs.droneFlags[f.Name] && !set[f.Name] && s.setBy[f.Name] == "" {
+			unset = append(unset, f.Name)
+		}
+	})
+	if len(unset) == 0 {
+		return
+	}
+	info, err := ReadGit(ctx, s.log, env["DRONE_WORKSPACE"])
+	if err != nil {
+		s.log.Debugf("[git] not reading local git metadata: %v", err)
+		return
+	}
+	values := info.values()
+	for _, name := range unset {
+		value := values[strings.TrimPrefix(name, flagNamePrefix)]
+		if value == "" {
+			continue
+		}
+		if err := s.fs.Set(name, value); err != nil {
+			s.log.Debugf("[git] '%s': %v", name, err)
+			continue
+		}
+		s.log.Debugf("[git] '%s' set: %s", name, value)
+		s.setBy[name] = "git"
+	}
+}
@@ -0,0 +1,106 @@
+package plug_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type gitPlugin struct {
+	Repo   plug.Repo
+	Commit plug.Commit
+}
+
+func (p *gitPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.RepoVar(&p.Repo)
+	fs.CommitVar(&p.Commit)
+	fs.GitFallback()
+}
+
+func (p *gitPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+// gitRepo creates a git repository with a single tagged commit.
+func gitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "plug-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	gitRun(t, dir, "init", "-q", "-b", "main")
+	gitRun(t, dir, "remote", "add", "origin", "git@github.com:octocat/hello-world.git")
+	gitRun(t, dir, "commit", "-q", "--allow-empty", "-m", "Initial commit\n\nWith a body.")
+	gitRun(t, dir, "tag", "v1.0.0")
+	return dir
+}
+
+// gitRun runs git with args in dir.
+func gitRun(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=Octo Cat", "-c", "user.email=octocat@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestReadGit(t *testing.T) {
+	dir := gitRepo(t)
+	info, err := plug.ReadGit(context.Background(), nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Repo.Owner != "octocat" || info.Repo.Name != "hello-world" ||
+		info.Repo.Link != "https://github.com/octocat/hello-world" {
+		t.Errorf("unexpected repo: %+v", info.Repo)
+	}
+	c := info.Commit
+	if len(c.Sha) != 40 || c.Branch != "main" || c.Ref != "refs/heads/main" ||
+		c.Message != "Initial commit\n\nWith a body." ||
+		c.Author.Name != "Octo Cat" || c.Author.Email != "octocat@example.com" {
+		t.Errorf("unexpected commit: %+v", c)
+	}
+	if len(info.Tags) != 1 || info.Tags[0] != "v1.0.0" {
+		t.Errorf("unexpected tags: %v", info.Tags)
+	}
+}
+
+func TestGitFallback(t *testing.T) {
+	env := map[string]string{
+		"DRONE":               "",
+		"DRONE_WORKSPACE":     gitRepo(t),
+		"DRONE_COMMIT_BRANCH": "feature",
+		"PLUGIN_PLUGIN_DEBUG": "true",
+	}
+	p := &gitPlugin{}
+	res, err := plug.Invoke(context.Background(), p, nil, plug.WithEnv(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Repo.Owner != "octocat" || p.Commit.Branch != "feature" || p.Commit.Author.Name != "Octo Cat" {
+		t.Errorf("unexpected values: %+v", p)
+	}
+	if !strings.Contains(res.Log, "commit_sha") ||
+		!strings.Contains(strings.Join(strings.Fields(res.Log), " "), "set by: git") {
+		t.Errorf("expected set by git in usage output:\n%s", res.Log)
+	}
+
+	p = &gitPlugin{}
+	if _, err := plug.Invoke(context.Background(), p, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p.Commit.Sha != "" {
+		t.Errorf("expected no git fallback in drone: %+v", p.Commit)
+	}
+}
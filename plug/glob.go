@@ -0,0 +1,165 @@
+package plug
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// compileGlob compiles a shell pattern to a regexp matching the whole input.
+// Unlike path.Match a * also matches slashes.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	re, err := globRegexp(glob, false)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern: %s", glob)
+	}
+	return re, nil
+}
+
+// compilePathGlob compiles the path glob pattern to a regexp.
+func compilePathGlob(pattern string) (*regexp.Regexp, error) {
+	re, err := globRegexp(pattern, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path glob %q: %v", pattern, err)
+	}
+	return re, nil
+}
+
+// globRegexp translates a glob to a regexp matching the whole input. Bracket
+// expressions support ! and ^ negation, a leading ] and POSIX classes such
+// as [[:alpha:]]. With paths set * and ? do not match slashes, neither do
+// bracket expressions, and ** matches any number of directories.
+func globRegexp(glob string, paths bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^(?s:")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			switch {
+			case !paths:
+				b.WriteString(".*")
+			case strings.HasPrefix(glob[i:], "**/"):
+				b.WriteString("(?:.*/)?")
+				i += 2
+			case strings.HasPrefix(glob[i:], "**"):
+				b.WriteString(".*")
+				i++
+			default:
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			if paths {
+				b.WriteString("[^/]")
+			} else {
+				b.WriteString(".")
+			}
+		case '[':
+			class, n, err := globClass(glob[i:], paths)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(class)
+			i += n - 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			_, size := utf8.DecodeRuneInString(glob[i:])
+			b.WriteString(regexp.QuoteMeta(glob[i : i+size]))
+			i += size - 1
+		default:
+			_, size := utf8.DecodeRuneInString(glob[i:])
+			b.WriteString(regexp.QuoteMeta(glob[i : i+size]))
+			i += size - 1
+		}
+	}
+	b.WriteString(")$")
+	return regexp.Compile(b.String())
+}
+
+// globClass translates the bracket expression at the start of glob to a
+// regexp character class and returns it with the length of the expression.
+func globClass(glob string, paths bool) (string, int, error) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		b.WriteString("^")
+		i++
+	}
+	for first := true; ; first = false {
+		if i >= len(glob) {
+			return "", 0, errors.New("missing ]")
+		}
+		c, escaped := glob[i], false
+		switch {
+		case c == ']' && !first:
+			b.WriteString("]")
+			class, err := excludeSlash(b.String(), paths)
+			return class, i + 1, err
+		case c == '[' && strings.HasPrefix(glob[i:], "[:"):
+			end := strings.Index(glob[i+2:], ":]")
+			if end < 0 {
+				return "", 0, errors.New("missing :]")
+			}
+			b.WriteString(glob[i : i+2+end+2])
+			i += 2 + end + 2
+			continue
+		case c == '\\' && i+1 < len(glob):
+			i++
+			c, escaped = glob[i], true
+		}
+		switch {
+		case c == '-' && !escaped:
+			b.WriteByte(c)
+		case c < utf8.RuneSelf && !isNameChar(c):
+			b.WriteString(`\` + string(c))
+		default:
+			b.WriteByte(c)
+		}
+		i++
+	}
+}
+
+// noMatch is a character class which matches nothing, for example a path
+// glob class of only slashes.
+const noMatch = `[^\x00-\x{10FFFF}]`
+
+// excludeSlash removes the slash from the regexp character class if paths is
+// set.
+func excludeSlash(class string, paths bool) (string, error) {
+	re, err := syntax.Parse(class, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !paths:
+		return class, nil
+	case re.Op == syntax.OpLiteral && string(re.Rune) == "/":
+		return noMatch, nil
+	case re.Op != syntax.OpCharClass:
+		return class, nil
+	}
+	var ranges []rune
+	for i := 0; i < len(re.Rune); i += 2 {
+		lo, hi := re.Rune[i], re.Rune[i+1]
+		if lo <= '/' && '/' <= hi {
+			if lo < '/' {
+				ranges = append(ranges, lo, '/'-1)
+			}
+			if hi > '/' {
+				ranges = append(ranges, '/'+1, hi)
+			}
+			continue
+		}
+		ranges = append(ranges, lo, hi)
+	}
+	if len(ranges) == 0 {
+		return noMatch, nil
+	}
+	re.Rune = ranges
+	return re.String(), nil
+}
@@ -0,0 +1,65 @@
+package plug_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		input string
+		path  bool // path glob match, see Constraint.MatchPath
+		shell bool // shell pattern match, see FlagSet.Interpolate
+	}{
+		{"*.go", "main.go", true, true},
+		{"*.go", "cmd/main.go", false, true},
+		{"**/*.go", "cmd/main.go", true, true},
+		{"**/*.go", "main.go", true, false},
+		{"docs/**", "docs/a/b.md", true, true},
+		{"?.go", "a.go", true, true},
+		{"a?b", "a/b", false, true},
+		{"[abc].go", "b.go", true, true},
+		{"[!abc].go", "d.go", true, true},
+		{"[^abc].go", "a.go", false, false},
+		{"a[!x]b", "a/b", false, true},
+		{"a[+-0]b", "a/b", false, true},
+		{"a[+-0]b", "a.b", true, true},
+		{"a[/]b", "a/b", false, true},
+		{"[]].go", "].go", true, true},
+		{"[!]].go", "].go", false, false},
+		{"[[:alpha:]].go", "x.go", true, true},
+		{"[[:alpha:]].go", "1.go", false, false},
+		{`[\]].go`, "].go", true, true},
+		{`[a\-z].go`, "-.go", true, true},
+		{`[a\-z].go`, "b.go", false, false},
+		{`\*.go`, "*.go", true, true},
+		{`\*.go`, "a.go", false, false},
+		{"ä*", "äpfel", true, true},
+	}
+	for _, tc := range tests {
+		var c plug.Constraint
+		data, _ := json.Marshal([]string{tc.glob})
+		if err := c.Set(string(data)); err != nil {
+			t.Errorf("%s: %v", tc.glob, err)
+			continue
+		}
+		if got := c.MatchPath(tc.input); got != tc.path {
+			t.Errorf("path glob %s matching %s: got %v", tc.glob, tc.input, got)
+		}
+		// ${VALUE##glob} is empty if glob matches all of VALUE.
+		p := &interpolatePlugin{}
+		_, err := plug.Invoke(context.Background(), p, plug.Settings{"message": "${VALUE##" + tc.glob + "}"},
+			plug.WithEnv(map[string]string{"VALUE": tc.input}))
+		if err != nil {
+			t.Errorf("%s: %v", tc.glob, err)
+			continue
+		}
+		if got := p.Message == ""; got != tc.shell {
+			t.Errorf("shell pattern %s matching %s: got %v", tc.glob, tc.input, got)
+		}
+	}
+}
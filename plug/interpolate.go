@@ -0,0 +1,367 @@
+package plug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Interpolate enables variable interpolation for the options bound to refs.
+// Values set from the environment have ${VAR} expressions replaced by values
+// from the step environment using the same envsubst semantics as drone:
+//
+//	$VAR ${VAR}                  value of VAR, $$ is a literal $
+//	${#VAR}                      length of VAR
+//	${VAR:-default} ${VAR-default} default if VAR is empty or unset (:-) or unset (-)
+//	${VAR:=default} ${VAR=default} same as :- and -
+//	${VAR:+alt} ${VAR+alt}       alt if VAR is not empty or set
+//	${VAR:?msg} ${VAR?msg}       usage error with msg if VAR is empty or unset
+//	${VAR:offset} ${VAR:offset:length} substring, negative offsets count from the end
+//	${VAR#glob} ${VAR##glob}     remove shortest or longest matching prefix
+//	${VAR%glob} ${VAR%%glob}     remove shortest or longest matching suffix
+//	${VAR/glob/repl} ${VAR//glob/repl} replace first or all matches
+//	${VAR/#glob/repl} ${VAR/%glob/repl} replace matching prefix or suffix
+//	${VAR^} ${VAR^^} ${VAR,} ${VAR,,} upper or lower case first or all characters
+//
+// Interpolation errors are reported as usage errors for the option.
+func (fs *FlagSet) Interpolate(refs ...interface{}) {
+	fs.interpolate = append(fs.interpolate, refs...)
+}
+
+// parseInterpolation interpolates the values of options enabled with
+// FlagSet.Interpolate which were set from env, including by an alias. It
+// must be called after parseAliases. Returns false if there were
+// interpolation errors.
+func (s *Service) parseInterpolation(env map[string]string) bool {
+	ok := true
+	for _, ref := range s.pfs.interpolate {
+		flg, err := s.findEnvFlag(ref)
+		if err != nil {
+			s.log.programmingFatalf("Interpolate: %v", err)
+		}
+		raw, set := flg.Value, flg.IsSelfSet
+		if v, ok := s.aliasValues[flg.Flag.Name]; ok && !set {
+			raw, set = v, true
+		}
+		if !set {
+			continue
+		}
+		value, err := envsubst(raw, env)
+		if err != nil {
+			s.addUsageError(flg.Flag.Name, fmt.Sprintf("interpolation error: %v", err))
+			ok = false
+			continue
+		}
+		if value == raw {
+			continue
+		}
+		s.log.Debugf("[interpolate] '%s': %s => %s", flg.Flag.Name, raw, value)
+		if err := s.fs.Set(flg.Flag.Name, value); err != nil {
+			s.addUsageError(flg.Flag.Name, fmt.Sprintf("invalid interpolated value %q: %v", value, err))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// envsubst replaces $VAR and ${VAR...} expressions in s with values from env.
+func envsubst(s string, env map[string]string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch c := s[i+1]; {
+		case c == '$':
+			b.WriteByte('$')
+			i++
+		case c == '{':
+			end := matchingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("missing closing brace in %q", s[i:])
+			}
+			v, err := expand(s[i+2:end], env)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end
+		case isNameStart(c):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			b.WriteString(env[s[i+1:j]])
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// matchingBrace returns the index of the } closing the expression starting
+// at i or -1.
+func matchingBrace(s string, i int) int {
+	depth := 1
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expand evaluates the contents of a ${...} expression.
+func expand(expr string, env map[string]string) (string, error) {
+	if strings.HasPrefix(expr, "#") && len(expr) > 1 && isName(expr[1:]) {
+		return strconv.Itoa(utf8.RuneCountInString(env[expr[1:]])), nil
+	}
+	n := 0
+	for n < len(expr) && isNameChar(expr[n]) {
+		n++
+	}
+	name, op := expr[:n], expr[n:]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("bad substitution: ${%s}", expr)
+	}
+	value, set := env[name]
+	word := func(w string) (string, error) {
+		return envsubst(w, env)
+	}
+
+	switch {
+	case op == "":
+		return value, nil
+	case op == "^^":
+		return strings.ToUpper(value), nil
+	case op == ",,":
+		return strings.ToLower(value), nil
+	case op == "^" || op == ",":
+		if value == "" {
+			return value, nil
+		}
+		r, size := utf8.DecodeRuneInString(value)
+		first := strings.ToUpper(string(r))
+		if op == "," {
+			first = strings.ToLower(string(r))
+		}
+		return first + value[size:], nil
+	case strings.HasPrefix(op, ":-"), strings.HasPrefix(op, ":="):
+		if value == "" {
+			return word(op[2:])
+		}
+		return value, nil
+	case strings.HasPrefix(op, "-"), strings.HasPrefix(op, "="):
+		if !set {
+			return word(op[1:])
+		}
+		return value, nil
+	case strings.HasPrefix(op, ":+"):
+		if value != "" {
+			return word(op[2:])
+		}
+		return "", nil
+	case strings.HasPrefix(op, "+"):
+		if set {
+			return word(op[1:])
+		}
+		return "", nil
+	case strings.HasPrefix(op, ":?"), strings.HasPrefix(op, "?"):
+		if (op[0] == ':' && value == "") || !set {
+			msg, err := word(strings.TrimPrefix(op[1:], "?"))
+			if err != nil {
+				return "", err
+			}
+			if msg == "" {
+				msg = "parameter null or not set"
+			}
+			return "", fmt.Errorf("%s: %s", name, msg)
+		}
+		return value, nil
+	case strings.HasPrefix(op, ":"):
+		return substr(value, op[1:])
+	case strings.HasPrefix(op, "##"):
+		return trimPrefix(value, op[2:], true)
+	case strings.HasPrefix(op, "#"):
+		return trimPrefix(value, op[1:], false)
+	case strings.HasPrefix(op, "%%"):
+		return trimSuffix(value, op[2:], true)
+	case strings.HasPrefix(op, "%"):
+		return trimSuffix(value, op[1:], false)
+	case strings.HasPrefix(op, "/"):
+		return replace(value, op[1:])
+	}
+	return "", fmt.Errorf("bad substitution: ${%s}", expr)
+}
+
+// substr evaluates the offset[:length] part of ${VAR:offset:length}.
+func substr(value, arg string) (string, error) {
+	parts := strings.SplitN(arg, ":", 2)
+	runes := []rune(value)
+	offset, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return "", fmt.Errorf("bad substring offset: %s", parts[0])
+	}
+	if offset < 0 {
+		offset += len(runes)
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > len(runes) {
+		offset = len(runes)
+	}
+	end := len(runes)
+	if len(parts) == 2 {
+		length, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return "", fmt.Errorf("bad substring length: %s", parts[1])
+		}
+		if length < 0 {
+			end += length
+		} else {
+			end = offset + length
+		}
+		if end > len(runes) {
+			end = len(runes)
+		}
+		if end < offset {
+			return "", fmt.Errorf("substring expression < 0: %s", arg)
+		}
+	}
+	return string(runes[offset:end]), nil
+}
+
+// trimPrefix removes the shortest or longest prefix matching glob.
+func trimPrefix(value, glob string, longest bool) (string, error) {
+	re, err := compileGlob(glob)
+	if err != nil {
+		return "", err
+	}
+	for _, i := range boundaries(value, longest) {
+		if re.MatchString(value[:i]) {
+			return value[i:], nil
+		}
+	}
+	return value, nil
+}
+
+// trimSuffix removes the shortest or longest suffix matching glob.
+func trimSuffix(value, glob string, longest bool) (string, error) {
+	re, err := compileGlob(glob)
+	if err != nil {
+		return "", err
+	}
+	for _, i := range boundaries(value, !longest) {
+		if re.MatchString(value[i:]) {
+			return value[:i], nil
+		}
+	}
+	return value, nil
+}
+
+// replace evaluates the glob/repl part of ${VAR/glob/repl} and its variants.
+// Like in bash the longest match is replaced.
+func replace(value, arg string) (string, error) {
+	mode := byte(0)
+	if arg != "" && (arg[0] == '/' || arg[0] == '#' || arg[0] == '%') {
+		mode, arg = arg[0], arg[1:]
+	}
+	parts := strings.SplitN(arg, "/", 2)
+	glob, repl := parts[0], ""
+	if len(parts) == 2 {
+		repl = parts[1]
+	}
+	if glob == "" {
+		return value, nil
+	}
+	re, err := compileGlob(glob)
+	if err != nil {
+		return "", err
+	}
+	match := re.MatchString
+	switch mode {
+	case '#':
+		for _, i := range boundaries(value, true) {
+			if match(value[:i]) {
+				return repl + value[i:], nil
+			}
+		}
+		return value, nil
+	case '%':
+		for _, i := range boundaries(value, false) {
+			if match(value[i:]) {
+				return value[:i] + repl, nil
+			}
+		}
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		end := -1
+		for _, j := range boundaries(value[i:], true) {
+			if j > 0 && match(value[i:i+j]) {
+				end = i + j
+				break
+			}
+		}
+		if end < 0 {
+			_, size := utf8.DecodeRuneInString(value[i:])
+			b.WriteString(value[i : i+size])
+			i += size
+			continue
+		}
+		b.WriteString(repl)
+		i = end
+		if mode != '/' {
+			b.WriteString(value[i:])
+			break
+		}
+	}
+	return b.String(), nil
+}
+
+// boundaries returns the rune boundary indexes of s including 0 and len(s)
+// in ascending order, or descending order if reverse is set.
+func boundaries(s string, reverse bool) []int {
+	var idx []int
+	for i := range s {
+		idx = append(idx, i)
+	}
+	idx = append(idx, len(s))
+	if reverse {
+		for i, j := 0, len(idx)-1; i < j; i, j = i+1, j-1 {
+			idx[i], idx[j] = idx[j], idx[i]
+		}
+	}
+	return idx
+}
+
+func isName(s string) bool {
+	if s == "" || !isNameStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || '0' <= c && c <= '9'
+}
@@ -0,0 +1,86 @@
+package plug_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type interpolatePlugin struct {
+	Message string
+}
+
+func (p *interpolatePlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Message, "message", "", "message")
+	fs.Interpolate(&p.Message)
+}
+
+func (p *interpolatePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{
+		"DRONE_TAG":        "v1.2.3",
+		"DRONE_COMMIT_SHA": "0123456789abcdef",
+		"DRONE_COMMIT_REF": "refs/heads/feature/x",
+		"DRONE_REPO_NAME":  "hello-world",
+		"EMPTY":            "",
+	}
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"$$DRONE_TAG", "$DRONE_TAG"},
+		{"tag $DRONE_TAG", "tag v1.2.3"},
+		{"${DRONE_TAG##v}", "1.2.3"},
+		{"${DRONE_TAG#v1.}", "2.3"},
+		{"${DRONE_TAG%.*}", "v1.2"},
+		{"${DRONE_TAG%%.*}", "v1"},
+		{"${DRONE_COMMIT_REF##*/}", "x"},
+		{"${DRONE_COMMIT_REF#refs/heads/}", "feature/x"},
+		{"deployed ${DRONE_COMMIT_SHA:0:8}", "deployed 01234567"},
+		{"${DRONE_COMMIT_SHA:10}", "abcdef"},
+		{"${DRONE_COMMIT_SHA: -4}", "cdef"},
+		{"${#DRONE_TAG}", "6"},
+		{"${DRONE_REPO_NAME^}", "Hello-world"},
+		{"${DRONE_REPO_NAME^^}", "HELLO-WORLD"},
+		{"${DRONE_REPO_NAME/-/_}", "hello_world"},
+		{"${DRONE_TAG//./-}", "v1-2-3"},
+		{"${DRONE_TAG/#v/V}", "V1.2.3"},
+		{"${DRONE_TAG/%3/4}", "v1.2.4"},
+		{"${UNSET:-default}", "default"},
+		{"${EMPTY:-default}", "default"},
+		{"${EMPTY-default}", ""},
+		{"${UNSET:-${DRONE_TAG}}", "v1.2.3"},
+		{"${DRONE_TAG:+set}", "set"},
+	}
+	for _, tc := range tests {
+		p := &interpolatePlugin{}
+		_, err := plug.Invoke(context.Background(), p, plug.Settings{"message": tc.value}, plug.WithEnv(env))
+		if err != nil {
+			t.Errorf("%s: %v", tc.value, err)
+			continue
+		}
+		if p.Message != tc.expected {
+			t.Errorf("%s: got %q, expected %q", tc.value, p.Message, tc.expected)
+		}
+	}
+}
+
+func TestInterpolateError(t *testing.T) {
+	for _, value := range []string{"${UNSET:?is required}", "${DRONE_TAG", "${DRONE_TAG@}"} {
+		_, err := plug.Invoke(context.Background(), &interpolatePlugin{}, plug.Settings{"message": value})
+		if err == nil {
+			t.Errorf("%s: expected an error", value)
+			continue
+		}
+		errs := err.(*plug.ExecError).UsageErrors["message"]
+		if len(errs) != 1 || !strings.HasPrefix(errs[0], "interpolation error") {
+			t.Errorf("%s: unexpected usage errors: %v", value, errs)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package plug
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"log"
+)
+
+// Result holds the outcome of a plugin run started with Invoke.
+type Result struct {
+	Outputs map[string]string // output variables set with Logger.SetOutputVar
+	Log     string            // everything written to the plugin Logger
+	Stdout  string            // everything written to Logger.Stdout
+	Stderr  string            // everything written to Logger.Stderr
+}
+
+// InvokeOption is used to configure plugin runs with the Invoke() function.
+type InvokeOption func(iv *invocation)
+
+type invocation struct {
+	name string
+	env  map[string]string
+	args []string
+	opts []ServiceOption
+}
+
+// WithDrone is an Invoke option to pass drone metadata to the plugin as DRONE_* variables.
+func WithDrone(d Drone) InvokeOption {
+	return func(iv *invocation) {
+		for k, v := range d.Env() {
+			iv.env[k] = v
+		}
+	}
+}
+
+// WithEnv is an Invoke option to pass additional raw environment variables to the plugin.
+func WithEnv(env map[string]string) InvokeOption {
+	return func(iv *invocation) {
+		for k, v := range env {
+			iv.env[k] = v
+		}
+	}
+}
+
+// WithArgs is an Invoke option to pass command line arguments to the plugin.
+func WithArgs(args ...string) InvokeOption {
+	return func(iv *invocation) {
+		iv.args = args
+	}
+}
+
+// WithName is an Invoke option to set the program name used in usage output.
+func WithName(name string) InvokeOption {
+	return func(iv *invocation) {
+		iv.name = name
+	}
+}
+
+// WithServiceOptions is an Invoke option to pass additional options to the
+// service, for example middleware installed with Use().
+func WithServiceOptions(opts ...ServiceOption) InvokeOption {
+	return func(iv *invocation) {
+		iv.opts = append(iv.opts, opts...)
+	}
+}
+
+// Invoke runs r in process with settings encoded as PLUGIN_* variables the
+// same way drone would. The runner goes through the same parse, validate and
+// exec steps as with Run but uses its own FlagSet, environment and logger so
+// the process state is left untouched. The returned error is nil or an
+// *ExecError.
+func Invoke(ctx context.Context, r Runner, settings Settings, opts ...InvokeOption) (*Result, error) {
+	iv := &invocation{
+		name: "plugin",
+		env:  map[string]string{"DRONE": "true"},
+	}
+	for _, o := range opts {
+		if o == nil {
+			panic("Invoke was given a nil InvokeOption")
+		}
+		o(iv)
+	}
+	env, err := settings.env()
+	if err != nil {
+		return &Result{}, &ExecError{Err: err}
+	}
+	for k, v := range env {
+		iv.env[k] = v
+	}
+	args := append([]string{iv.name}, iv.args...)
+	var buf, stdout, stderr bytes.Buffer
+	fs := flag.NewFlagSet(iv.name, flag.ContinueOnError)
+	fs.SetOutput(&buf)
+	s := NewService(append([]ServiceOption{
+		SetFlagSet(fs),
+		SetEnvFunc(func() map[string]string { return iv.env }),
+		SetArgsFunc(func() []string { return args }),
+		SetLogger(log.New(&buf, "", 0)),
+		SetStdout(&stdout),
+		SetStderr(&stderr),
+		ContinueOnError(),
+	}, iv.opts...)...)
+	s.RunContext(ctx, r)
+	res := &Result{
+		Outputs: s.Outputs(),
+		Log:     buf.String(),
+		Stdout:  stdout.String(),
+		Stderr:  stderr.String(),
+	}
+	return res, s.Err()
+}
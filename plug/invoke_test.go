@@ -0,0 +1,179 @@
+package plug_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type invokePlugin struct {
+	Server string
+	Repos  []string
+	Labels map[string]string
+	Fork   bool
+	Drone  plug.Drone
+}
+
+func (p *invokePlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Server, "server", "", "drone server")
+	fs.StringSliceVar(&p.Repos, "repositories", "repositories to trigger")
+	fs.StringMapVar(&p.Labels, "labels", "labels")
+	fs.BoolVar(&p.Fork, "fork", false, "fork")
+	fs.DroneVar(&p.Drone)
+}
+
+func (p *invokePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	if p.Server == "" {
+		log.Usageln(&p.Server, "server is required")
+		return plug.ErrUsageError
+	}
+	log.Println("triggered", len(p.Repos))
+	log.SetOutputVar("branch", p.Drone.Commit.Branch)
+	return nil
+}
+
+func TestInvoke(t *testing.T) {
+	p := &invokePlugin{}
+	res, err := plug.Invoke(context.Background(), p, plug.Settings{
+		"server":       "https://drone.example.com",
+		"repositories": []interface{}{"octocat/hello", "octocat/world"},
+		"labels":       map[string]interface{}{"a": "b"},
+		"fork":         true,
+	}, plug.WithDrone(plug.Drone{
+		Build:  plug.Build{Number: 7},
+		Commit: plug.Commit{Branch: "master"},
+	}))
+	if err != nil {
+		t.Fatal(err, res.Log)
+	}
+	if !reflect.DeepEqual(p.Repos, []string{"octocat/hello", "octocat/world"}) {
+		t.Errorf("unexpected repos: %v", p.Repos)
+	}
+	if p.Labels["a"] != "b" || !p.Fork || p.Drone.Build.Number != 7 {
+		t.Errorf("unexpected plugin state: %+v", p)
+	}
+	if res.Outputs["branch"] != "master" {
+		t.Errorf("unexpected outputs: %v", res.Outputs)
+	}
+	if res.Log != "triggered 2\n" {
+		t.Errorf("unexpected log: %q", res.Log)
+	}
+}
+
+func TestInvokeUsageError(t *testing.T) {
+	_, err := plug.Invoke(context.Background(), &invokePlugin{}, nil)
+	execErr, ok := err.(*plug.ExecError)
+	if !ok {
+		t.Fatalf("expected *plug.ExecError, got %T", err)
+	}
+	if len(execErr.UsageErrors["server"]) != 1 {
+		t.Errorf("expected usage error for server, got %v", execErr.UsageErrors)
+	}
+}
+
+func TestSettingsEnv(t *testing.T) {
+	env := plug.Settings{
+		"name":        "value",
+		"more.flower": []string{"a", "b"},
+		"count":       3,
+		"enabled":     false,
+		"nested":      []interface{}{map[string]interface{}{"a": 1}},
+	}.Env()
+	expected := map[string]string{
+		"PLUGIN_NAME":        "value",
+		"PLUGIN_MORE_FLOWER": "a,b",
+		"PLUGIN_COUNT":       "3",
+		"PLUGIN_ENABLED":     "false",
+		"PLUGIN_NESTED":      `[{"a":1}]`,
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("got %v, expected %v", env, expected)
+	}
+}
+
+func TestInvokeSettingsError(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		_, err := plug.Invoke(context.Background(), &stdoutPlugin{}, plug.Settings{
+			"callback": map[string]interface{}{"fn": func() {}},
+			"channel":  make(chan int),
+		})
+		if err == nil || !strings.Contains(err.Error(), "setting callback") {
+			t.Fatalf("expected an encoding error for the first setting, got %v", err)
+		}
+	}
+}
+
+func TestDroneEnvRoundTrip(t *testing.T) {
+	env := plug.ParseDrone(map[string]string{"DRONE_REPO_NAME": "hello"}).Env()
+	expected := map[string]string{"DRONE": "true", "DRONE_REPO_NAME": "hello"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("got %v, expected %v", env, expected)
+	}
+}
+
+type stdoutPlugin struct{}
+
+func (p *stdoutPlugin) SetFlags(fs *plug.FlagSet) {}
+
+func (p *stdoutPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	_, err := io.WriteString(log.Stdout(), "hello")
+	return err
+}
+
+func TestInvokeStdout(t *testing.T) {
+	res, err := plug.Invoke(context.Background(), &stdoutPlugin{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stdout != "hello" || res.Log != "" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+type argsPlugin struct {
+	Server   string
+	Count    int
+	Insecure bool
+}
+
+func (p *argsPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Server, "server", "", "server")
+	fs.IntVar(&p.Count, "count", 0, "count")
+	fs.BoolVar(&p.Insecure, "insecure", false, "skip tls verification")
+}
+
+func (p *argsPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestInvokeArgsErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		flag string
+	}{
+		{[]string{"-nope"}, ""},
+		{[]string{"---server=x"}, ""},
+		{[]string{"-server"}, "server"},
+		{[]string{"-count=x"}, "count"},
+		{[]string{"--count", "x"}, "count"},
+		{[]string{"-insecure=x"}, "insecure"},
+	}
+	for _, tc := range tests {
+		res, err := plug.Invoke(context.Background(), &argsPlugin{}, nil, plug.WithArgs(tc.args...))
+		execErr, ok := err.(*plug.ExecError)
+		if !ok {
+			t.Errorf("%v: expected *plug.ExecError, got %v", tc.args, err)
+			continue
+		}
+		if errs := execErr.UsageErrors[tc.flag]; len(errs) != 1 || len(execErr.UsageErrors) != 1 {
+			t.Errorf("%v: expected an usage error for %q, got %v", tc.args, tc.flag, execErr.UsageErrors)
+		}
+		if !strings.Contains(res.Log, "**USAGE ERROR**") {
+			t.Errorf("%v: expected the usage error in the usage output:\n%s", tc.args, res.Log)
+		}
+	}
+}
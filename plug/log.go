@@ -2,28 +2,75 @@ package plug
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"reflect"
+	"strings"
 
 	"github.com/go-pa/fenv"
 )
 
 // Logger works like log.Log with additional features for drone plugin specific usage
+//
+// A Logger is safe for concurrent use by multiple goroutines.
 type Logger struct {
 	logger *log.Logger
 	s      *Service
-	// mu     sync.Mutex
+	prefix string // prepended to all output, set by With
+}
+
+// With returns a child logger which prefixes its output and usage errors with
+// key=value. It is intended for giving each goroutine of a plugin that does
+// work in parallel its own logger so that interleaved output stays readable.
+func (l *Logger) With(key string, value interface{}) *Logger {
+	return &Logger{
+		logger: l.logger,
+		s:      l.s,
+		prefix: fmt.Sprintf("%s[%s=%v] ", l.prefix, key, value),
+	}
 }
 
 // Output forwards logging output to the configured logger or the stdlib log.Output if no custom logger is defined.
 func (l *Logger) Output(calldepth int, s string) error {
+	return l.output(calldepth+1, l.prefix+s)
+}
+
+// output writes s without adding the logger prefix.
+func (l *Logger) output(calldepth int, s string) error {
 	if l.logger == nil {
 		return log.Output(calldepth+1, s)
 	}
 	return l.logger.Output(calldepth+1, s)
 }
 
+// Stdin returns the standard input of the plugin, os.Stdin unless set with
+// the SetStdin service option.
+func (l *Logger) Stdin() io.Reader {
+	if l.s == nil || l.s.stdin == nil {
+		return os.Stdin
+	}
+	return l.s.stdin
+}
+
+// Stdout returns the writer plugins and helpers use for output which is not
+// logging, such as the output of commands. It is os.Stdout unless set with
+// the SetStdout service option.
+func (l *Logger) Stdout() io.Writer {
+	if l.s == nil || l.s.stdout == nil {
+		return os.Stdout
+	}
+	return l.s.stdout
+}
+
+// Stderr is like Stdout for error output, os.Stderr unless set with the
+// SetStderr service option.
+func (l *Logger) Stderr() io.Writer {
+	if l.s == nil || l.s.stderr == nil {
+		return os.Stderr
+	}
+	return l.s.stderr
+}
+
 // These functions write to the standard logger.
 
 // Debug calls Output to print to the standard logger if plugins debug is enabled.
@@ -91,6 +138,15 @@ func (l *Logger) Fatalln(v ...interface{}) {
 	os.Exit(1)
 }
 
+// SetOutputVar sets an output variable which is returned by Invoke and
+// written to the DRONE_OUTPUT env file when the plugin has finished.
+func (l *Logger) SetOutputVar(name, value string) {
+	l.Debugf("[output] %s=%s", name, value)
+	l.s.mu.Lock()
+	l.s.outputs[name] = value
+	l.s.mu.Unlock()
+}
+
 // for internal use, triggers if the API is in an bad state
 func (l *Logger) programmingFatalf(format string, v ...interface{}) {
 	_ = l.Output(2, "programming error: "+fmt.Sprintf(format, v...))
@@ -99,82 +155,109 @@ func (l *Logger) programmingFatalf(format string, v ...interface{}) {
 
 // Usage TODO
 func (l *Logger) Usage(ref interface{}, v ...interface{}) {
-	if l.s.debug {
-		_ = l.Output(2, l.fmtFlagUsage(ref, fmt.Sprint(v...)))
-	}
-	flg, err := l.findEnvFlag(ref)
-	if err != nil {
-		_ = l.Output(2, err.Error())
-		os.Exit(1)
-	}
-	errs := l.s.usageErrors[flg.Flag.Name]
-	errs = append(errs, fmt.Sprint(v...))
-	l.s.log.s.usageErrors[flg.Flag.Name] = errs
-
+	l.usage(ref, fmt.Sprint(v...))
 }
 
 // Usagef TODO
 func (l *Logger) Usagef(ref interface{}, format string, v ...interface{}) {
-	if l.s.debug {
-		_ = l.Output(2, l.fmtFlagUsage(ref, fmt.Sprintf(format, v...)))
-	}
-	flg, err := l.findEnvFlag(ref)
-	if err != nil {
-		_ = l.Output(2, err.Error())
-		os.Exit(1)
-	}
-	errs := l.s.usageErrors[flg.Flag.Name]
-	errs = append(errs, fmt.Sprintf(format, v...))
-	l.s.usageErrors[flg.Flag.Name] = errs
-
+	l.usage(ref, fmt.Sprintf(format, v...))
 }
 
 // Usageln TODO
 func (l *Logger) Usageln(ref interface{}, v ...interface{}) {
+	l.usage(ref, fmt.Sprintln(v...))
+}
+
+// UsageGlobal registers an usage error which is not tied to a single option,
+// for example when two options conflict with each other.
+// Arguments are handled in the manner of fmt.Print.
+func (l *Logger) UsageGlobal(v ...interface{}) {
+	l.usageGlobal(fmt.Sprint(v...))
+}
+
+// UsageGlobalf registers an usage error which is not tied to a single option.
+// Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) UsageGlobalf(format string, v ...interface{}) {
+	l.usageGlobal(fmt.Sprintf(format, v...))
+}
+
+// UsageGloballn registers an usage error which is not tied to a single option.
+// Arguments are handled in the manner of fmt.Println.
+func (l *Logger) UsageGloballn(v ...interface{}) {
+	l.usageGlobal(fmt.Sprintln(v...))
+}
+
+// Warn registers a warning for the option bound to ref. Warnings are shown in
+// the usage output but do not fail the build.
+// Arguments are handled in the manner of fmt.Print.
+func (l *Logger) Warn(ref interface{}, v ...interface{}) {
+	l.warn(ref, fmt.Sprint(v...))
+}
+
+// Warnf registers a warning for the option bound to ref.
+// Arguments are handled in the manner of fmt.Printf.
+func (l *Logger) Warnf(ref interface{}, format string, v ...interface{}) {
+	l.warn(ref, fmt.Sprintf(format, v...))
+}
+
+// Warnln registers a warning for the option bound to ref.
+// Arguments are handled in the manner of fmt.Println.
+func (l *Logger) Warnln(ref interface{}, v ...interface{}) {
+	l.warn(ref, fmt.Sprintln(v...))
+}
+
+// usage registers msg as an usage error for the flag bound to ref. If ref is
+// not bound to a flag the error is registered as a global usage error.
+func (l *Logger) usage(ref interface{}, msg string) {
+	msg = l.prefix + msg
+	flg, err := l.s.findEnvFlag(ref)
+	if err != nil {
+		l.Debugln(err)
+		l.s.addUsageError("", msg)
+		return
+	}
 	if l.s.debug {
-		_ = l.Output(2, l.fmtFlagUsage(ref, fmt.Sprintln(v...)))
+		_ = l.output(3, fmtFlagMessage(flg, "error", msg))
 	}
-	flg, err := l.findEnvFlag(ref)
-	if err != nil {
-		_ = l.Output(2, err.Error())
-		os.Exit(1)
+	l.s.addUsageError(flg.Flag.Name, msg)
+}
+
+func (l *Logger) usageGlobal(msg string) {
+	msg = l.prefix + msg
+	if l.s.debug {
+		_ = l.output(3, "plugin usage error: "+msg)
 	}
-	errs := l.s.usageErrors[flg.Flag.Name]
-	errs = append(errs, fmt.Sprintln(v...))
-	l.s.usageErrors[flg.Flag.Name] = errs
+	l.s.addUsageError("", msg)
 }
 
-func (l *Logger) fmtFlagUsage(ref interface{}, rest string) string {
-	flg, err := l.findEnvFlag(ref)
+// warn registers msg as a warning for the flag bound to ref. If ref is not
+// bound to a flag the warning is registered as a global warning.
+func (l *Logger) warn(ref interface{}, msg string) {
+	msg = l.prefix + msg
+	flg, err := l.s.findEnvFlag(ref)
 	if err != nil {
 		l.Debugln(err)
-	} else {
-		l.Debugf("adding usage error for '%s': %s", flg.Flag.Name, rest)
-		l.s.usageErrors[flg.Flag.Name] = append(l.s.usageErrors[flg.Flag.Name], rest)
-	}
-	fmtname := flg.Flag.Name
-	if flg != nil {
-		if flg.Name != "" {
-			fmtname = fmtDroneYMLName(flg.Name)
-		}
+		l.s.addWarning("", msg)
+		return
 	}
-	return fmt.Sprintf("plugin option '%s' error: %s", fmtname, rest)
+	l.s.addWarning(flg.Flag.Name, msg)
 }
 
-func (l *Logger) findEnvFlag(value interface{}) (*fenv.EnvFlag, error) {
-	// returns the flag.Flag instace bound to ref or nil if not found
-	rv := reflect.ValueOf(value)
-	if rv.Kind() != reflect.Ptr {
-		return nil, fmt.Errorf("not a pointer: %v", value)
+// fmtFlagMessage formats an usage error or warning for display.
+func fmtFlagMessage(flg *fenv.EnvFlag, kind, rest string) string {
+	return fmt.Sprintf("plugin option '%s' %s: %s", fmtFlagName(flg), kind, rest)
+}
+
+// fmtFlagName returns the .drone.yml name of a flag, preferring the name it
+// was set by. The flag name is returned for flags without setting names.
+func fmtFlagName(flg *fenv.EnvFlag) string {
+	if flg.Name != "" {
+		return fmtDroneYMLName(flg.Name)
 	}
-	vp := rv.Pointer()
-	var flg *fenv.EnvFlag
-	l.s.es.VisitAll(func(f fenv.EnvFlag) {
-		p := reflect.ValueOf(f.Flag.Value).Pointer()
-		if vp == p {
-			flg = &f
+	for _, name := range flg.Names {
+		if strings.HasPrefix(name, "PLUGIN_") {
+			return fmtDroneYMLName(name)
 		}
-	})
-	return flg, nil
-
+	}
+	return flg.Flag.Name
 }
@@ -0,0 +1,133 @@
+package plug_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type parallelPlugin struct {
+	Repos []string
+}
+
+func (p *parallelPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringSliceVar(&p.Repos, "repositories", "repositories to trigger")
+}
+
+func (p *parallelPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	var wg sync.WaitGroup
+	for _, repo := range p.Repos {
+		wg.Add(1)
+		go func(repo string) {
+			defer wg.Done()
+			log := log.With("repo", repo)
+			log.Println("triggered")
+			log.SetOutputVar(repo, "ok")
+			log.Usagef(&p.Repos, "could not trigger")
+		}(repo)
+	}
+	wg.Wait()
+	return nil
+}
+
+func TestLoggerConcurrentUse(t *testing.T) {
+	var repos []string
+	for i := 0; i < 20; i++ {
+		repos = append(repos, fmt.Sprintf("octocat/%d", i))
+	}
+	res, err := plug.Invoke(context.Background(), &parallelPlugin{}, plug.Settings{
+		"repositories": repos,
+	})
+	if err == nil {
+		t.Fatal("expected usage errors")
+	}
+	if n := len(err.(*plug.ExecError).UsageErrors["repositories"]); n != len(repos) {
+		t.Errorf("expected %d usage errors, got %d", len(repos), n)
+	}
+	if len(res.Outputs) != len(repos) {
+		t.Errorf("expected %d outputs, got %v", len(repos), res.Outputs)
+	}
+	if !strings.Contains(res.Log, "[repo=octocat/7] triggered\n") {
+		t.Errorf("expected prefixed log output, got:\n%s", res.Log)
+	}
+}
+
+type warnPlugin struct {
+	Server    string
+	OldServer string
+	Insecure  bool
+	conflict  bool
+	fail      bool
+}
+
+func (p *warnPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Server, "server", "", "drone server")
+	fs.StringVar(&p.OldServer, "drone_server", "", "drone server")
+	fs.Deprecated(&p.OldServer, "use server")
+	fs.BoolVar(&p.Insecure, "insecure", false, "skip tls verification")
+}
+
+func (p *warnPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	if p.Insecure {
+		log.Warnf(&p.Insecure, "tls verification is disabled")
+	}
+	if p.conflict {
+		log.UsageGlobalf("server and drone_server can not both be set")
+		return plug.ErrUsageError
+	}
+	if p.fail {
+		return errors.New("deploy failed")
+	}
+	return nil
+}
+
+func TestWarnings(t *testing.T) {
+	res, err := plug.Invoke(context.Background(), &warnPlugin{}, plug.Settings{
+		"drone_server": "https://drone.example.com",
+		"insecure":     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "plugin option 'drone_server' warning: deprecated: use server\n" +
+		"plugin option 'insecure' warning: tls verification is disabled\n"
+	if res.Log != expected {
+		t.Errorf("unexpected output:\n%s", res.Log)
+	}
+}
+
+func TestWarningsExecError(t *testing.T) {
+	res, err := plug.Invoke(context.Background(), &warnPlugin{fail: true}, plug.Settings{
+		"insecure": true,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.HasPrefix(res.Log, "plugin option 'insecure' warning: tls verification is disabled\n") {
+		t.Errorf("expected warning before the error report:\n%s", res.Log)
+	}
+}
+
+func TestUsageGlobal(t *testing.T) {
+	res, err := plug.Invoke(context.Background(), &warnPlugin{conflict: true}, plug.Settings{
+		"insecure": true,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	errs := err.(*plug.ExecError).UsageErrors[""]
+	if len(errs) != 1 || errs[0] != "server and drone_server can not both be set" {
+		t.Errorf("unexpected global usage errors: %v", errs)
+	}
+	if !strings.Contains(res.Log, "server and drone_server can not both be set") {
+		t.Errorf("expected global usage error in usage output:\n%s", res.Log)
+	}
+	if strings.Count(res.Log, "tls verification is disabled") != 1 || !strings.Contains(res.Log, "WARNINGS") {
+		t.Errorf("expected a single warning in the WARNINGS section:\n%s", res.Log)
+	}
+}
@@ -0,0 +1,292 @@
+package plug
+
+import "flag"
+
+// Metadata is passed to functions registered with FlagSet.DefaultFunc.
+//
+// The embedded Drone is read from the DRONE_* env vars like with
+// FlagSet.DroneVar, so integer fields such as Build.Number are -1 when their
+// variable is not set. Reads of the Drone fields are not tracked, use the
+// accessor methods such as CommitSha to list the variables in the usage
+// output. Reads through Getenv and Option are listed as well.
+type Metadata struct {
+	Drone // drone metadata read from the DRONE_* env vars
+
+	env     map[string]string
+	fs      *flag.FlagSet
+	sources *[]string // env vars and options read by the function
+}
+
+// read records name as a source of the computed value.
+func (m Metadata) read(name string) {
+	if m.sources == nil {
+		return
+	}
+	for _, s := range *m.sources {
+		if s == name {
+			return
+		}
+	}
+	*m.sources = append(*m.sources, name)
+}
+
+// Getenv returns the value of a variable in the step environment. The
+// environment of a Metadata created outside of DefaultFunc is Drone.Env().
+func (m Metadata) Getenv(name string) string {
+	m.read(name)
+	if m.env == nil {
+		return m.Drone.Env()[name]
+	}
+	return m.env[name]
+}
+
+// Option returns the value of another option by flag name. Options are
+// defaulted in the order they were registered.
+func (m Metadata) Option(name string) string {
+	if m.fs == nil {
+		return ""
+	}
+	f := m.fs.Lookup(name)
+	if f == nil {
+		return ""
+	}
+	m.read(name)
+	return f.Value.String()
+}
+
+// RepoFullName returns the repository full name (DRONE_REPO).
+func (m Metadata) RepoFullName() string {
+	m.read("DRONE_REPO")
+	if m.Repo.Owner == "" || m.Repo.Name == "" {
+		return ""
+	}
+	return m.Repo.Owner + "/" + m.Repo.Name
+}
+
+// RepoOwner returns the repository owner (DRONE_REPO_OWNER).
+func (m Metadata) RepoOwner() string {
+	m.read("DRONE_REPO_OWNER")
+	return m.Repo.Owner
+}
+
+// RepoName returns the repository name (DRONE_REPO_NAME).
+func (m Metadata) RepoName() string {
+	m.read("DRONE_REPO_NAME")
+	return m.Repo.Name
+}
+
+// RepoLink returns the repository link (DRONE_REPO_LINK).
+func (m Metadata) RepoLink() string {
+	m.read("DRONE_REPO_LINK")
+	return m.Repo.Link
+}
+
+// RepoAvatar returns the repository avatar (DRONE_REPO_AVATAR).
+func (m Metadata) RepoAvatar() string {
+	m.read("DRONE_REPO_AVATAR")
+	return m.Repo.Avatar
+}
+
+// RepoBranch returns the repository default branch (DRONE_REPO_BRANCH).
+func (m Metadata) RepoBranch() string {
+	m.read("DRONE_REPO_BRANCH")
+	return m.Repo.Branch
+}
+
+// RepoPrivate returns the repository is private (DRONE_REPO_PRIVATE).
+func (m Metadata) RepoPrivate() bool {
+	m.read("DRONE_REPO_PRIVATE")
+	return m.Repo.Private
+}
+
+// RepoTrusted returns the repository is trusted (DRONE_REPO_TRUSTED).
+func (m Metadata) RepoTrusted() bool {
+	m.read("DRONE_REPO_TRUSTED")
+	return m.Repo.Trusted
+}
+
+// BuildNumber returns the build number (DRONE_BUILD_NUMBER).
+func (m Metadata) BuildNumber() int64 {
+	m.read("DRONE_BUILD_NUMBER")
+	return m.Build.Number
+}
+
+// BuildEvent returns the build event (DRONE_BUILD_EVENT).
+func (m Metadata) BuildEvent() string {
+	m.read("DRONE_BUILD_EVENT")
+	return m.Build.Event
+}
+
+// BuildStatus returns the build status (DRONE_BUILD_STATUS).
+func (m Metadata) BuildStatus() string {
+	m.read("DRONE_BUILD_STATUS")
+	return m.Build.Status
+}
+
+// BuildDeployTo returns the build deployment target (DRONE_DEPLOY_TO).
+func (m Metadata) BuildDeployTo() string {
+	m.read("DRONE_DEPLOY_TO")
+	return m.Build.Deploy
+}
+
+// BuildCreated returns the build created unix timestamp (DRONE_BUILD_CREATED).
+func (m Metadata) BuildCreated() int64 {
+	m.read("DRONE_BUILD_CREATED")
+	return m.Build.Created
+}
+
+// BuildStarted returns the build started unix timestamp (DRONE_BUILD_STARTED).
+func (m Metadata) BuildStarted() int64 {
+	m.read("DRONE_BUILD_STARTED")
+	return m.Build.Started
+}
+
+// BuildFinished returns the build finished unix timestamp (DRONE_BUILD_FINISHED).
+func (m Metadata) BuildFinished() int64 {
+	m.read("DRONE_BUILD_FINISHED")
+	return m.Build.Finished
+}
+
+// BuildLink returns the build result link (DRONE_BUILD_LINK).
+func (m Metadata) BuildLink() string {
+	m.read("DRONE_BUILD_LINK")
+	return m.Build.Link
+}
+
+// CommitSha returns the commit sha (DRONE_COMMIT_SHA).
+func (m Metadata) CommitSha() string {
+	m.read("DRONE_COMMIT_SHA")
+	return m.Commit.Sha
+}
+
+// CommitBefore returns the commit sha before the push (DRONE_COMMIT_BEFORE).
+func (m Metadata) CommitBefore() string {
+	m.read("DRONE_COMMIT_BEFORE")
+	return m.Commit.Before
+}
+
+// CommitRef returns the commit ref (DRONE_COMMIT_REF).
+func (m Metadata) CommitRef() string {
+	m.read("DRONE_COMMIT_REF")
+	return m.Commit.Ref
+}
+
+// CommitLink returns the commit link in remote (DRONE_COMMIT_LINK).
+func (m Metadata) CommitLink() string {
+	m.read("DRONE_COMMIT_LINK")
+	return m.Commit.Link
+}
+
+// CommitBranch returns the commit branch (DRONE_COMMIT_BRANCH).
+func (m Metadata) CommitBranch() string {
+	m.read("DRONE_COMMIT_BRANCH")
+	return m.Commit.Branch
+}
+
+// CommitTargetBranch returns the pull request target branch (DRONE_TARGET_BRANCH).
+func (m Metadata) CommitTargetBranch() string {
+	m.read("DRONE_TARGET_BRANCH")
+	return m.Commit.TargetBranch
+}
+
+// CommitMessage returns the commit message (DRONE_COMMIT_MESSAGE).
+func (m Metadata) CommitMessage() string {
+	m.read("DRONE_COMMIT_MESSAGE")
+	return m.Commit.Message
+}
+
+// CommitAuthorName returns the commit author username (DRONE_COMMIT_AUTHOR_NAME).
+func (m Metadata) CommitAuthorName() string {
+	m.read("DRONE_COMMIT_AUTHOR_NAME")
+	return m.Commit.Author.Name
+}
+
+// CommitAuthorEmail returns the commit author email address (DRONE_COMMIT_AUTHOR_EMAIL).
+func (m Metadata) CommitAuthorEmail() string {
+	m.read("DRONE_COMMIT_AUTHOR_EMAIL")
+	return m.Commit.Author.Email
+}
+
+// CommitAuthorAvatar returns the commit author avatar (DRONE_COMMIT_AUTHOR_AVATAR).
+func (m Metadata) CommitAuthorAvatar() string {
+	m.read("DRONE_COMMIT_AUTHOR_AVATAR")
+	return m.Commit.Author.Avatar
+}
+
+// StageName returns the stage name (DRONE_STAGE_NAME).
+func (m Metadata) StageName() string {
+	m.read("DRONE_STAGE_NAME")
+	return m.Stage.Name
+}
+
+// StageNumber returns the stage number (DRONE_STAGE_NUMBER).
+func (m Metadata) StageNumber() int64 {
+	m.read("DRONE_STAGE_NUMBER")
+	return m.Stage.Number
+}
+
+// StageKind returns the stage kind (DRONE_STAGE_KIND).
+func (m Metadata) StageKind() string {
+	m.read("DRONE_STAGE_KIND")
+	return m.Stage.Kind
+}
+
+// StageType returns the stage type (DRONE_STAGE_TYPE).
+func (m Metadata) StageType() string {
+	m.read("DRONE_STAGE_TYPE")
+	return m.Stage.Type
+}
+
+// StageStatus returns the stage status (DRONE_STAGE_STATUS).
+func (m Metadata) StageStatus() string {
+	m.read("DRONE_STAGE_STATUS")
+	return m.Stage.Status
+}
+
+// StageStarted returns the stage started unix timestamp (DRONE_STAGE_STARTED).
+func (m Metadata) StageStarted() int64 {
+	m.read("DRONE_STAGE_STARTED")
+	return m.Stage.Started
+}
+
+// StageFinished returns the stage finished unix timestamp (DRONE_STAGE_FINISHED).
+func (m Metadata) StageFinished() int64 {
+	m.read("DRONE_STAGE_FINISHED")
+	return m.Stage.Finished
+}
+
+// StageMachine returns the stage runner machine name (DRONE_STAGE_MACHINE).
+func (m Metadata) StageMachine() string {
+	m.read("DRONE_STAGE_MACHINE")
+	return m.Stage.Machine
+}
+
+// StageOS returns the stage operating system (DRONE_STAGE_OS).
+func (m Metadata) StageOS() string {
+	m.read("DRONE_STAGE_OS")
+	return m.Stage.OS
+}
+
+// StageArch returns the stage architecture (DRONE_STAGE_ARCH).
+func (m Metadata) StageArch() string {
+	m.read("DRONE_STAGE_ARCH")
+	return m.Stage.Arch
+}
+
+// StageVariant returns the stage architecture variant (DRONE_STAGE_VARIANT).
+func (m Metadata) StageVariant() string {
+	m.read("DRONE_STAGE_VARIANT")
+	return m.Stage.Variant
+}
+
+// StepName returns the step name (DRONE_STEP_NAME).
+func (m Metadata) StepName() string {
+	m.read("DRONE_STEP_NAME")
+	return m.Step.Name
+}
+
+// StepNumber returns the step number (DRONE_STEP_NUMBER).
+func (m Metadata) StepNumber() int64 {
+	m.read("DRONE_STEP_NUMBER")
+	return m.Step.Number
+}
@@ -0,0 +1,144 @@
+package plug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// ExecFunc is the function signature of Runner.Exec.
+type ExecFunc func(ctx context.Context, log *Logger) error
+
+// Middleware wraps an ExecFunc to add behaviour around Runner.Exec.
+type Middleware func(next ExecFunc) ExecFunc
+
+// MiddlewareRunner is implemented by Runners which declare their own
+// middleware. Runner middleware is installed inside of the middleware given
+// to the service with Use().
+type MiddlewareRunner interface {
+	Runner
+	Middleware() []Middleware
+}
+
+// Use is a NewService option to install middleware around Runner.Exec. The
+// first middleware given is the outermost one.
+func Use(mw ...Middleware) ServiceOption {
+	for _, m := range mw {
+		if m == nil {
+			panic("Use was given a nil Middleware")
+		}
+	}
+	return func(s *Service) {
+		s.middleware = append(s.middleware, mw...)
+	}
+}
+
+// chain wraps fn with mw so that mw[0] is the outermost middleware.
+func chain(fn ExecFunc, mw ...Middleware) ExecFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		fn = mw[i](fn)
+	}
+	return fn
+}
+
+// PanicError is returned by the Recover middleware when Exec panics.
+type PanicError struct {
+	Value interface{} // value passed to panic
+	Stack []byte      // stack trace of the panicking goroutine
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recover returns a middleware which converts a panic in Exec into a
+// PanicError. The stack trace is logged in debug mode.
+func Recover() Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, log *Logger) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					pe := &PanicError{Value: v, Stack: debug.Stack()}
+					log.Debugf("recovered %v\n%s", pe, pe.Stack)
+					err = pe
+				}
+			}()
+			return next(ctx, log)
+		}
+	}
+}
+
+// Elapsed returns a middleware which logs the time spent in Exec.
+func Elapsed() Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, log *Logger) error {
+			start := time.Now()
+			err := next(ctx, log)
+			log.Printf("plugin finished in %v", time.Since(start).Round(time.Millisecond))
+			return err
+		}
+	}
+}
+
+// transientError marks an error as safe to retry.
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string   { return e.err.Error() }
+func (e transientError) Unwrap() error   { return e.err }
+func (e transientError) Transient() bool { return true }
+
+// Transient marks err as a transient error which is retried by the Retry
+// middleware. Errors which implement Transient() bool are also recognized.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return transientError{err: err}
+}
+
+// IsTransient reports whether any error in err's chain is transient.
+func IsTransient(err error) bool {
+	var t interface{ Transient() bool }
+	return errors.As(err, &t) && t.Transient()
+}
+
+// Retry returns a middleware which runs Exec up to attempts times as long as
+// it returns a transient error, waiting delay between attempts. Usage errors,
+// warnings and output variables of failed attempts are dropped before the
+// next attempt.
+func Retry(attempts int, delay time.Duration) Middleware {
+	if attempts < 1 {
+		panic("Retry attempts must be at least 1")
+	}
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, log *Logger) error {
+			var (
+				err   error
+				state execState
+			)
+			if log.s != nil {
+				state = log.s.saveState()
+			}
+			for i := 1; i <= attempts; i++ {
+				if i > 1 && log.s != nil {
+					log.s.restoreState(state)
+				}
+				err = next(ctx, log)
+				if err == nil || !IsTransient(err) || i == attempts {
+					return err
+				}
+				log.Printf("attempt %d/%d failed, retrying in %v: %v", i, attempts, delay, err)
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("%v (last error: %w)", ctx.Err(), err)
+				case <-time.After(delay):
+				}
+			}
+			return err
+		}
+	}
+}
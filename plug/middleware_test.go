@@ -0,0 +1,127 @@
+package plug_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type funcPlugin struct {
+	exec       plug.ExecFunc
+	middleware []plug.Middleware
+}
+
+func (p *funcPlugin) SetFlags(fs *plug.FlagSet) {}
+
+func (p *funcPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return p.exec(ctx, log)
+}
+
+func (p *funcPlugin) Middleware() []plug.Middleware {
+	return p.middleware
+}
+
+func trace(calls *[]string, name string) plug.Middleware {
+	return func(next plug.ExecFunc) plug.ExecFunc {
+		return func(ctx context.Context, log *plug.Logger) error {
+			*calls = append(*calls, name)
+			return next(ctx, log)
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	p := &funcPlugin{
+		exec: func(ctx context.Context, log *plug.Logger) error {
+			calls = append(calls, "exec")
+			return nil
+		},
+		middleware: []plug.Middleware{trace(&calls, "runner")},
+	}
+	_, err := plug.Invoke(context.Background(), p, nil, plug.WithServiceOptions(
+		plug.Use(trace(&calls, "first"), trace(&calls, "second")),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(calls, ",") != "first,second,runner,exec" {
+		t.Fatal(calls)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	p := &funcPlugin{exec: func(ctx context.Context, log *plug.Logger) error {
+		panic("boom")
+	}}
+	_, err := plug.Invoke(context.Background(), p, nil, plug.WithServiceOptions(plug.Use(plug.Recover())))
+	var pe *plug.PanicError
+	if !errors.As(err.(*plug.ExecError).Err, &pe) || pe.Value != "boom" {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var attempts int
+	p := &funcPlugin{exec: func(ctx context.Context, log *plug.Logger) error {
+		attempts++
+		if attempts < 3 {
+			return plug.Transient(errors.New("unavailable"))
+		}
+		return nil
+	}}
+	_, err := plug.Invoke(context.Background(), p, nil, plug.WithServiceOptions(plug.Use(plug.Retry(3, 0))))
+	if err != nil || attempts != 3 {
+		t.Fatalf("expected success after 3 attempts, got %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	p.exec = func(ctx context.Context, log *plug.Logger) error {
+		attempts++
+		return errors.New("permanent")
+	}
+	_, err = plug.Invoke(context.Background(), p, nil, plug.WithServiceOptions(plug.Use(plug.Retry(3, 0))))
+	if err == nil || attempts != 1 {
+		t.Fatalf("expected a single failed attempt, got %v after %d", err, attempts)
+	}
+}
+
+func TestRetryDropsFailedAttempts(t *testing.T) {
+	var attempts int
+	p := &funcPlugin{exec: func(ctx context.Context, log *plug.Logger) error {
+		attempts++
+		log.SetOutputVar("attempt", strconv.Itoa(attempts))
+		if attempts == 1 {
+			log.SetOutputVar("stale", "true")
+			log.UsageGlobalf("server unavailable")
+			log.Warnf(nil, "slow server")
+			return plug.Transient(errors.New("unavailable"))
+		}
+		return nil
+	}}
+	res, err := plug.Invoke(context.Background(), p, nil, plug.WithServiceOptions(plug.Use(plug.Retry(2, 0))))
+	if err != nil {
+		t.Fatalf("expected the usage error of the failed attempt to be dropped, got %v", err)
+	}
+	if len(res.Outputs) != 1 || res.Outputs["attempt"] != "2" {
+		t.Fatalf("expected only the outputs of the last attempt, got %v", res.Outputs)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &funcPlugin{exec: func(ctx context.Context, log *plug.Logger) error {
+		cancel()
+		return plug.Transient(errors.New("unavailable"))
+	}}
+	_, err := plug.Invoke(ctx, p, nil, plug.WithServiceOptions(plug.Use(plug.Retry(3, time.Hour))))
+	var ee *plug.ExecError
+	if !errors.As(err, &ee) || !plug.IsTransient(ee.Err) || ee.Err.Error() != "context canceled (last error: unavailable)" {
+		t.Fatalf("expected the last error to be wrapped, got %v", err)
+	}
+}
@@ -1,10 +1,19 @@
 package plugtest
 
+import (
+	"errors"
+	"flag"
+	"reflect"
+	"strings"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
 func (t *PT) AssertSuccess() {
 	t.T.Helper()
 	t.after()
 	if t.Err != nil {
-		t.T.Log(t.logbuf.String())
+		t.T.Log(t.out.String())
 		t.T.Fatal("should have succeeded", t.Err)
 	}
 }
@@ -13,13 +22,12 @@ func (t *PT) AssertFail() {
 	t.T.Helper()
 	t.after()
 	if t.Err == nil {
-		t.T.Log(t.logbuf.String())
+		t.T.Log(t.out.String())
 		t.T.Fatal("should have failed")
 	}
 
 }
 
-
 func (t *PT) AssertOutput(text string) {
 	t.T.Helper()
 	t.after()
@@ -29,3 +37,112 @@ func (t *PT) AssertOutput(text string) {
 		t.T.Fatalf("output not as expected!\n got:\n%s\n expected:\n%s", out, text)
 	}
 }
+
+// AssertUsageError asserts that a usage error containing msg was registered
+// for the option with the flag name. Global usage errors use the empty name.
+func (t *PT) AssertUsageError(name, msg string) {
+	t.T.Helper()
+	t.after()
+	errs := t.usageErrors()
+	for _, e := range errs[name] {
+		if strings.Contains(e, msg) {
+			return
+		}
+	}
+	t.T.Log(t.out.String())
+	t.T.Fatalf("no usage error containing %q for '%s', usage errors: %v", msg, name, errs)
+}
+
+// AssertNoUsageErrors asserts that no usage errors were registered.
+func (t *PT) AssertNoUsageErrors() {
+	t.T.Helper()
+	t.after()
+	if errs := t.usageErrors(); len(errs) > 0 {
+		t.T.Log(t.out.String())
+		t.T.Fatalf("expected no usage errors, got: %v", errs)
+	}
+}
+
+// AssertOptionSetBy asserts how the option with the flag name was set:
+// the env var name, "flag" or the set by description shown in the usage
+// output such as "git" or "alias old_name".
+func (t *PT) AssertOptionSetBy(name, setBy string) {
+	t.T.Helper()
+	state := t.option(name)
+	if state.SetBy != setBy {
+		t.T.Fatalf("option '%s' set by %q, expected %q", name, state.SetBy, setBy)
+	}
+}
+
+// AssertOptionValue asserts the value of the option with the flag name. A
+// string is compared with the string form of the value, other types with
+// the parsed value, for example []string for StringSliceVar options.
+func (t *PT) AssertOptionValue(name string, value interface{}) {
+	t.T.Helper()
+	state := t.option(name)
+	var got interface{}
+	if s, ok := value.(string); ok {
+		got = state.Value.String()
+		if got == s {
+			return
+		}
+	} else {
+		got = optionValue(state.Value, reflect.TypeOf(value))
+		if reflect.DeepEqual(got, value) {
+			return
+		}
+	}
+	t.T.Fatalf("option '%s' has value %#v, expected %#v", name, got, value)
+}
+
+// AssertExitCode asserts the exit code the plugin would exit with when run
+// as a program, 0 on success.
+func (t *PT) AssertExitCode(code int) {
+	t.T.Helper()
+	t.after()
+	got := 0
+	if t.Err != nil {
+		got = 1
+		var ee *plug.ExecError
+		if errors.As(t.Err, &ee) {
+			got = ee.ExitCode()
+		}
+	}
+	if got != code {
+		t.T.Log(t.out.String())
+		t.T.Fatalf("exit code %d, expected %d: %v", got, code, t.Err)
+	}
+}
+
+func (t *PT) usageErrors() map[string][]string {
+	var ee *plug.ExecError
+	if errors.As(t.Err, &ee) {
+		return ee.UsageErrors
+	}
+	return nil
+}
+
+func (t *PT) option(name string) plug.OptionState {
+	t.T.Helper()
+	t.after()
+	state, ok := t.s.Option(name)
+	if !ok {
+		t.T.Fatalf("no option '%s'", name)
+	}
+	return state
+}
+
+// optionValue returns the parsed value of v, converted to typ if possible.
+func optionValue(v flag.Value, typ reflect.Type) interface{} {
+	if g, ok := v.(flag.Getter); ok {
+		return g.Get()
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if typ != nil && rv.Type().ConvertibleTo(typ) {
+		return rv.Convert(typ).Interface()
+	}
+	return rv.Interface()
+}
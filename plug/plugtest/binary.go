@@ -0,0 +1,295 @@
+package plugtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DefaultBinaryTimeout is the default time limit for running a plugin binary.
+var DefaultBinaryTimeout = time.Minute
+
+// BinaryTest runs a compiled plugin as a subprocess, which also covers
+// os.Exit and global state which in process tests with PT can not. It is
+// created with Binary.
+type BinaryTest struct {
+	T *testing.T
+
+	path    string
+	env     map[string]string
+	args    []string
+	stdin   io.Reader
+	dir     string
+	timeout time.Duration
+
+	hasRun   bool
+	exitCode int
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+}
+
+// Binary compiles the plugin main package pkg, for example "." or
+// "./cmd/plugin", and returns a test which runs it. Each package is only
+// compiled once per test binary into a temporary directory which is removed
+// by Main. The environment of the plugin contains DRONE=true, PATH and HOME
+// but nothing else from the test process.
+func Binary(t *testing.T, pkg string) *BinaryTest {
+	t.Helper()
+	path, err := buildBinary(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &BinaryTest{
+		T:       t,
+		path:    path,
+		env:     map[string]string{"DRONE": "true"},
+		timeout: DefaultBinaryTimeout,
+	}
+	for _, k := range []string{"PATH", "HOME", "SYSTEMROOT"} {
+		if v, ok := os.LookupEnv(k); ok {
+			b.env[k] = v
+		}
+	}
+	return b
+}
+
+// Main runs the tests and removes the binaries compiled by Binary, call it
+// from TestMain in packages which use Binary:
+//
+//	func TestMain(m *testing.M) {
+//		plugtest.Main(m)
+//	}
+func Main(m *testing.M) {
+	code := m.Run()
+	removeBinaries()
+	os.Exit(code)
+}
+
+var binaries struct {
+	mu    sync.Mutex
+	dir   string
+	paths map[string]string
+}
+
+// removeBinaries removes the directory with the compiled binaries.
+func removeBinaries() {
+	binaries.mu.Lock()
+	defer binaries.mu.Unlock()
+	if binaries.dir != "" {
+		os.RemoveAll(binaries.dir)
+		binaries.dir, binaries.paths = "", nil
+	}
+}
+
+// buildBinary compiles pkg into a temporary directory shared by the tests.
+func buildBinary(pkg string) (string, error) {
+	abs, err := filepath.Abs(pkg)
+	if err != nil {
+		return "", err
+	}
+	binaries.mu.Lock()
+	defer binaries.mu.Unlock()
+	if path, ok := binaries.paths[abs]; ok {
+		return path, nil
+	}
+	if binaries.dir == "" {
+		if binaries.dir, err = ioutil.TempDir("", "plugtest-bin"); err != nil {
+			return "", err
+		}
+		binaries.paths = make(map[string]string)
+	}
+	name := filepath.Base(abs)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	path := filepath.Join(binaries.dir, strings.Replace(abs, string(filepath.Separator), "_", -1), name)
+	gobin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(gobin); err != nil {
+		gobin = "go"
+	}
+	cmd := exec.Command(gobin, "build", "-o", path, pkg)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", errors.New("go build " + pkg + ": " + err.Error() + "\n" + string(out))
+	}
+	binaries.paths[abs] = path
+	return path, nil
+}
+
+// SetVars sets environment variables, names are upper cased.
+func (b *BinaryTest) SetVars(vars map[string]string) {
+	b.T.Helper()
+	b.before()
+	for k, v := range vars {
+		b.env[strings.ToUpper(k)] = v
+	}
+}
+
+// SetPluginVars sets PLUGIN_ prefixed environment variables.
+func (b *BinaryTest) SetPluginVars(vars map[string]string) {
+	b.T.Helper()
+	b.before()
+	for k, v := range vars {
+		b.env[strings.ToUpper("plugin_"+k)] = v
+	}
+}
+
+// SetArgs sets the command line arguments.
+func (b *BinaryTest) SetArgs(args ...string) {
+	b.T.Helper()
+	b.before()
+	b.args = args
+}
+
+// SetStdin sets the standard input of the plugin.
+func (b *BinaryTest) SetStdin(r io.Reader) {
+	b.T.Helper()
+	b.before()
+	b.stdin = r
+}
+
+// SetTimeout sets the time after which the plugin is killed and the test
+// fails, DefaultBinaryTimeout by default.
+func (b *BinaryTest) SetTimeout(d time.Duration) {
+	b.T.Helper()
+	b.before()
+	b.timeout = d
+}
+
+// Workdir creates a temporary working directory for the plugin which is
+// removed when the test finishes and returns it. Relative paths given to
+// AssertFile are resolved against it.
+func (b *BinaryTest) Workdir() string {
+	b.T.Helper()
+	b.before()
+	if b.dir == "" {
+		b.dir = b.T.TempDir()
+	}
+	return b.dir
+}
+
+// Run runs the plugin and returns its exit code. It is called by the
+// assertions if it has not been called yet.
+func (b *BinaryTest) Run() int {
+	b.T.Helper()
+	if b.hasRun {
+		b.T.Fatal("this test has already run")
+	}
+	b.hasRun = true
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, b.path, b.args...)
+	cmd.Dir = b.dir
+	cmd.Stdin = b.stdin
+	cmd.Stdout = &b.stdout
+	cmd.Stderr = &b.stderr
+	var keys []string
+	for k := range b.env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmd.Env = append(cmd.Env, k+"="+b.env[k])
+	}
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		b.T.Fatalf("plugin did not finish within %v\nstdout:\n%s\nstderr:\n%s", b.timeout, &b.stdout, &b.stderr)
+	}
+	var eerr *exec.ExitError
+	switch {
+	case errors.As(err, &eerr):
+		b.exitCode = eerr.ExitCode()
+	case err != nil:
+		b.T.Fatal(err)
+	}
+	return b.exitCode
+}
+
+// Stdout returns the standard output of the plugin.
+func (b *BinaryTest) Stdout() string {
+	b.T.Helper()
+	b.after()
+	return b.stdout.String()
+}
+
+// Stderr returns the standard error of the plugin, which contains the log
+// and usage output.
+func (b *BinaryTest) Stderr() string {
+	b.T.Helper()
+	b.after()
+	return b.stderr.String()
+}
+
+// AssertExitCode asserts the exit code of the plugin.
+func (b *BinaryTest) AssertExitCode(code int) {
+	b.T.Helper()
+	b.after()
+	if b.exitCode != code {
+		b.T.Fatalf("exit code %d, expected %d\nstdout:\n%s\nstderr:\n%s", b.exitCode, code, &b.stdout, &b.stderr)
+	}
+}
+
+// AssertStdout asserts that the standard output of the plugin is text.
+func (b *BinaryTest) AssertStdout(text string) {
+	b.T.Helper()
+	if out := b.Stdout(); out != text {
+		b.T.Fatalf("stdout not as expected!\n got:\n%s\n expected:\n%s", out, text)
+	}
+}
+
+// AssertStderrContains asserts that the standard error of the plugin
+// contains text.
+func (b *BinaryTest) AssertStderrContains(text string) {
+	b.T.Helper()
+	if out := b.Stderr(); !strings.Contains(out, text) {
+		b.T.Fatalf("stderr does not contain %q:\n%s", text, out)
+	}
+}
+
+// AssertGolden compares the normalized standard error with the golden file
+// like PT.AssertGolden.
+func (b *BinaryTest) AssertGolden(filename string) {
+	b.T.Helper()
+	assertGolden(b.T, filename, normalize(b.Stderr()))
+}
+
+// AssertFile asserts that the file at path, relative to Workdir, has
+// content.
+func (b *BinaryTest) AssertFile(path, content string) {
+	b.T.Helper()
+	b.after()
+	if !filepath.IsAbs(path) && b.dir != "" {
+		path = filepath.Join(b.dir, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		b.T.Fatal(err)
+	}
+	if string(data) != content {
+		b.T.Fatalf("file %s not as expected!\n got:\n%s\n expected:\n%s", path, data, content)
+	}
+}
+
+func (b *BinaryTest) before() {
+	b.T.Helper()
+	if b.hasRun {
+		b.T.Fatal("has already run")
+	}
+}
+
+func (b *BinaryTest) after() {
+	b.T.Helper()
+	if !b.hasRun {
+		b.Run()
+	}
+}
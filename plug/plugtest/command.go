@@ -0,0 +1,218 @@
+package plugtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+// Command is a command run by the plugin through a FakeCommander.
+type Command struct {
+	Args  []string
+	Env   []string
+	Dir   string
+	Stdin string
+}
+
+func (c Command) String() string {
+	return strings.Join(c.Args, " ")
+}
+
+// FakeCommander is a plug.Commander which records commands instead of
+// running them and replies with scripted responses.
+type FakeCommander struct {
+	mu        sync.Mutex
+	responses []*Response
+	commands  []Command
+}
+
+// Response is the scripted result of the commands matching a pattern, see
+// FakeCommander.On.
+type Response struct {
+	pattern  []string
+	stdout   string
+	stderr   string
+	exitCode int
+	err      error
+}
+
+// Stdout sets the standard output of the command.
+func (r *Response) Stdout(s string) *Response {
+	r.stdout = s
+	return r
+}
+
+// Stderr sets the standard error of the command.
+func (r *Response) Stderr(s string) *Response {
+	r.stderr = s
+	return r
+}
+
+// ExitCode sets the exit code of the command.
+func (r *Response) ExitCode(code int) *Response {
+	r.exitCode = code
+	return r
+}
+
+// Err makes the command fail to start with err.
+func (r *Response) Err(err error) *Response {
+	r.err = err
+	return r
+}
+
+// On registers the response for commands matching pattern. Each element of
+// pattern is a glob matched against the argument at the same position and
+// a last element of "..." matches any remaining arguments. The first
+// matching response is used. Commands without a matching response exit
+// with code 127.
+func (f *FakeCommander) On(pattern ...string) *Response {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := &Response{pattern: pattern}
+	f.responses = append(f.responses, r)
+	return r
+}
+
+// Commands returns the commands run so far in order.
+func (f *FakeCommander) Commands() []Command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Command(nil), f.commands...)
+}
+
+// Run implements plug.Commander.
+func (f *FakeCommander) Run(ctx context.Context, cmd *plug.Cmd) error {
+	c := Command{
+		Args: append([]string(nil), cmd.Args...),
+		Env:  append([]string(nil), cmd.Env...),
+		Dir:  cmd.Dir,
+	}
+	if cmd.Stdin != nil {
+		data, err := ioutil.ReadAll(cmd.Stdin)
+		if err != nil {
+			return err
+		}
+		c.Stdin = string(data)
+	}
+	f.mu.Lock()
+	f.commands = append(f.commands, c)
+	var r *Response
+	for _, resp := range f.responses {
+		if matchArgs(resp.pattern, c.Args) {
+			r = resp
+			break
+		}
+	}
+	f.mu.Unlock()
+
+	if r == nil {
+		r = &Response{
+			stderr:   fmt.Sprintf("plugtest: no fake response for command: %s\n", c),
+			exitCode: 127,
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if cmd.Stdout != nil {
+		_, _ = io.WriteString(cmd.Stdout, r.stdout)
+	}
+	if cmd.Stderr != nil {
+		_, _ = io.WriteString(cmd.Stderr, r.stderr)
+	}
+	if r.exitCode != 0 {
+		return &plug.CommandError{Args: c.Args, ExitCode: r.exitCode}
+	}
+	return nil
+}
+
+// matchArgs reports whether args match pattern, see FakeCommander.On.
+func matchArgs(pattern, args []string) bool {
+	for i, p := range pattern {
+		if p == "..." && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(args) {
+			return false
+		}
+		if ok, _ := filepath.Match(p, args[i]); !ok {
+			return false
+		}
+	}
+	return len(pattern) == len(args)
+}
+
+// FakeCommands installs a FakeCommander which records the commands the
+// plugin runs with plug.Logger.Command instead of running them. The git
+// commands run by the plug package, for example for FlagSet.PathsVar, are
+// faked as well.
+func (t *PT) FakeCommands() *FakeCommander {
+	t.T.Helper()
+	t.before()
+	if t.commander == nil {
+		t.commander = &FakeCommander{}
+	}
+	return t.commander
+}
+
+// AssertCommand asserts that a command matching pattern was run, see
+// FakeCommander.On for the pattern syntax.
+func (t *PT) AssertCommand(pattern ...string) {
+	t.T.Helper()
+	if t.findCommand(0, pattern) < 0 {
+		t.T.Fatalf("no command matching %q was run, commands:\n%s", pattern, t.fmtCommands())
+	}
+}
+
+// AssertNoCommand asserts that no command matching pattern was run.
+func (t *PT) AssertNoCommand(pattern ...string) {
+	t.T.Helper()
+	if i := t.findCommand(0, pattern); i >= 0 {
+		t.T.Fatalf("command %d matching %q should not have been run, commands:\n%s", i+1, pattern, t.fmtCommands())
+	}
+}
+
+// AssertCommandOrder asserts that commands matching the patterns were run in
+// the given order, other commands may run in between.
+func (t *PT) AssertCommandOrder(patterns ...[]string) {
+	t.T.Helper()
+	i := 0
+	for _, pattern := range patterns {
+		j := t.findCommand(i, pattern)
+		if j < 0 {
+			t.T.Fatalf("no command matching %q was run after command %d, commands:\n%s", pattern, i, t.fmtCommands())
+		}
+		i = j + 1
+	}
+}
+
+// findCommand returns the index of the first command from index start
+// matching pattern or -1.
+func (t *PT) findCommand(start int, pattern []string) int {
+	t.T.Helper()
+	t.after()
+	if t.commander == nil {
+		t.T.Fatal("no fake commands, call FakeCommands before running the plugin")
+	}
+	commands := t.commander.Commands()
+	for i := start; i < len(commands); i++ {
+		if matchArgs(pattern, commands[i].Args) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *PT) fmtCommands() string {
+	var lines []string
+	for i, c := range t.commander.Commands() {
+		lines = append(lines, fmt.Sprintf("  %d: %s", i+1, c))
+	}
+	return strings.Join(lines, "\n")
+}
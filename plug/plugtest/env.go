@@ -1,6 +1,12 @@
 package plugtest
 
-import "strings"
+import (
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
 
 func (t *PT) SetDebug() {
 	t.T.Helper()
@@ -24,6 +30,47 @@ func (t *PT) SetVars(o map[string]string) {
 	}
 }
 
+// SetArgs sets the command line arguments passed to the plugin after the
+// program name.
+func (t *PT) SetArgs(args ...string) {
+	t.T.Helper()
+	t.before()
+	t.args = args
+}
+
+// WriteEnvFile writes vars to an env file named name in a temporary
+// directory which is removed when the test finishes and returns its path,
+// for use with -env_file or PLUGIN_ENV_FILE.
+func (t *PT) WriteEnvFile(name string, vars map[string]string) string {
+	t.T.Helper()
+	t.before()
+	filename := filepath.Join(t.T.TempDir(), name)
+	if err := godotenv.Write(vars, filename); err != nil {
+		t.T.Fatal(err)
+	}
+	return filename
+}
+
+// SetStdin sets the reader returned by plug.Logger.Stdin.
+func (t *PT) SetStdin(r io.Reader) {
+	t.T.Helper()
+	t.before()
+	t.stdin = r
+}
+
+// SetWorkdir sets the working directory while the plugin runs, relative
+// paths are resolved when SetWorkdir is called. The working directory is
+// process wide so tests using it can not run in parallel.
+func (t *PT) SetWorkdir(dir string) {
+	t.T.Helper()
+	t.before()
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		t.T.Fatal(err)
+	}
+	t.workdir = dir
+}
+
 func (t *PT) envFunc() map[string]string {
 	return t.env
 }
@@ -0,0 +1,333 @@
+package plugtest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+// Fixture builds a complete and consistent drone environment for a build
+// event, like the one drone runners pass to plugin steps. Fixtures are
+// created with the event presets Push, PullRequest, Tag, Promote and Cron
+// and can be changed with Update and Set before the plugin runs. The
+// DRONE_* variables which derive from a field, such as DRONE_REPO from the
+// repo owner and name, follow changes of that field.
+type Fixture struct {
+	plug.Drone // repo, build, commit, stage and step metadata
+
+	Tag          string // DRONE_TAG and DRONE_SEMVER_*
+	PullRequest  int    // DRONE_PULL_REQUEST
+	SourceBranch string // DRONE_SOURCE_BRANCH
+	Cron         string // DRONE_CRON
+	Workspace    string // DRONE_WORKSPACE
+	System       System
+	Netrc        Netrc
+
+	overrides map[string]string
+	pt        *PT      // the test the fixture is applied to
+	applied   []string // env vars set on pt
+}
+
+// System holds the DRONE_SYSTEM_* variables of a fixture.
+type System struct {
+	Proto   string
+	Host    string
+	Version string
+}
+
+// Netrc holds the DRONE_NETRC_* variables of a fixture.
+type Netrc struct {
+	Machine  string
+	Username string
+	Password string
+}
+
+// newFixture returns a fixture for a successful build of the default
+// branch of octocat/hello-world.
+func newFixture(event plug.Event) *Fixture {
+	const started = 1577836800 // 2020-01-01T00:00:00Z
+	return &Fixture{
+		Drone: plug.Drone{
+			Repo: plug.Repo{
+				Owner:  "octocat",
+				Name:   "hello-world",
+				Link:   "https://github.com/octocat/hello-world",
+				Avatar: "https://avatars.githubusercontent.com/u/583231",
+				Branch: "main",
+			},
+			Build: plug.Build{
+				Number:  1,
+				Event:   string(event),
+				Status:  "success",
+				Created: started,
+				Started: started,
+				Link:    "https://drone.example.com/octocat/hello-world/1",
+			},
+			Commit: plug.Commit{
+				Sha:     "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+				Ref:     "refs/heads/main",
+				Link:    "https://github.com/octocat/hello-world/commit/7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+				Branch:  "main",
+				Message: "Update README.md",
+				Author: plug.Author{
+					Name:   "octocat",
+					Email:  "octocat@github.com",
+					Avatar: "https://avatars.githubusercontent.com/u/583231",
+				},
+			},
+			Stage: plug.Stage{
+				Name:    "default",
+				Number:  1,
+				Kind:    "pipeline",
+				Type:    "docker",
+				Status:  "success",
+				Started: started,
+				Machine: "runner-1",
+				OS:      "linux",
+				Arch:    "amd64",
+			},
+			Step: plug.Step{
+				Name:   "plugin",
+				Number: 2,
+			},
+		},
+		SourceBranch: "main",
+		Workspace:    "/drone/src",
+		System: System{
+			Proto:   "https",
+			Host:    "drone.example.com",
+			Version: "1.10.1",
+		},
+		Netrc: Netrc{
+			Machine:  "github.com",
+			Username: "octocat",
+			Password: "x-oauth-basic",
+		},
+		overrides: make(map[string]string),
+	}
+}
+
+// Push returns a fixture for a push to branch.
+func Push(branch string) *Fixture {
+	f := newFixture(plug.EventPush)
+	f.Commit.Branch = branch
+	f.Commit.Ref = "refs/heads/" + branch
+	f.Commit.Before = "553c2077f0edc3d5dc5d17262f6aa498e69d6f8e"
+	f.SourceBranch = branch
+	f.Commit.TargetBranch = branch
+	return f
+}
+
+// PullRequest returns a fixture for pull request number from the source to
+// the target branch.
+func PullRequest(number int, source, target string) *Fixture {
+	f := newFixture(plug.EventPullRequest)
+	f.PullRequest = number
+	f.Commit.Branch = target
+	f.Commit.Ref = fmt.Sprintf("refs/pull/%d/head", number)
+	f.Commit.Link = fmt.Sprintf("%s/pull/%d", f.Repo.Link, number)
+	f.SourceBranch = source
+	f.Commit.TargetBranch = target
+	return f
+}
+
+// Tag returns a fixture for pushing tag. Tags which are semantic versions,
+// with or without a v prefix, also set the DRONE_SEMVER_* variables.
+func Tag(tag string) *Fixture {
+	f := newFixture(plug.EventTag)
+	f.Tag = tag
+	f.Commit.Branch = tag
+	f.Commit.Ref = "refs/tags/" + tag
+	f.SourceBranch = tag
+	f.Commit.TargetBranch = tag
+	return f
+}
+
+// Promote returns a fixture for promoting build 1 of the default branch to
+// the deployment target.
+func Promote(target string) *Fixture {
+	f := newFixture(plug.EventPromote)
+	f.Build.Number = 2
+	f.Build.Deploy = target
+	f.Build.Link = "https://drone.example.com/octocat/hello-world/2"
+	f.Commit.TargetBranch = f.Commit.Branch
+	f.overrides["DRONE_BUILD_PARENT"] = "1"
+	return f
+}
+
+// Cron returns a fixture for a build of the default branch started by the
+// cron job name.
+func Cron(name string) *Fixture {
+	f := newFixture(plug.EventCron)
+	f.Cron = name
+	f.Commit.TargetBranch = f.Commit.Branch
+	return f
+}
+
+// Update calls fn to change the fields of f.
+func (f *Fixture) Update(fn func(f *Fixture)) *Fixture {
+	f.before()
+	fn(f)
+	f.apply()
+	return f
+}
+
+// Set overrides an environment variable, an empty value removes it.
+func (f *Fixture) Set(name, value string) *Fixture {
+	f.before()
+	f.overrides[strings.ToUpper(name)] = value
+	f.apply()
+	return f
+}
+
+// Env returns the environment variables of the fixture.
+func (f *Fixture) Env() map[string]string {
+	env := f.Drone.Env()
+	env["CI"] = "true"
+	str := func(name, value string) {
+		if value != "" {
+			env[name] = value
+		}
+	}
+	fullName := f.Repo.Owner + "/" + f.Repo.Name
+	str("DRONE_REPO_NAMESPACE", f.Repo.Owner)
+	str("DRONE_REPO_SCM", "git")
+	visibility := "public"
+	if f.Repo.Private {
+		visibility = "private"
+	}
+	str("DRONE_REPO_VISIBILITY", visibility)
+	str("DRONE_REPO_PRIVATE", strconv.FormatBool(f.Repo.Private))
+	if f.Repo.Link != "" {
+		str("DRONE_GIT_HTTP_URL", f.Repo.Link+".git")
+		str("DRONE_REMOTE_URL", f.Repo.Link+".git")
+	}
+	str("DRONE_GIT_SSH_URL", fmt.Sprintf("git@%s:%s.git", f.Netrc.Machine, fullName))
+
+	str("DRONE_BRANCH", f.Commit.Branch)
+	str("DRONE_COMMIT", f.Commit.Sha)
+	str("DRONE_COMMIT_AFTER", f.Commit.Sha)
+	str("DRONE_COMMIT_AUTHOR", f.Commit.Author.Name)
+	str("DRONE_SOURCE_BRANCH", f.SourceBranch)
+	str("DRONE_BUILD_TRIGGER", "@hook")
+	if f.Build.EventType() == plug.EventCron {
+		str("DRONE_BUILD_TRIGGER", "@cron")
+	}
+	if f.Build.EventType() == plug.EventPromote {
+		str("DRONE_BUILD_TRIGGER", f.Commit.Author.Name)
+	}
+	if f.PullRequest > 0 {
+		str("DRONE_PULL_REQUEST", strconv.Itoa(f.PullRequest))
+		str("DRONE_BUILD_ACTION", "opened")
+	}
+	str("DRONE_TAG", f.Tag)
+	if f.Tag != "" {
+		for k, v := range semverEnv(f.Tag) {
+			str(k, v)
+		}
+	}
+	str("DRONE_CRON", f.Cron)
+	str("DRONE_WORKSPACE", f.Workspace)
+	str("DRONE_SYSTEM_PROTO", f.System.Proto)
+	str("DRONE_SYSTEM_HOST", f.System.Host)
+	str("DRONE_SYSTEM_HOSTNAME", f.System.Host)
+	str("DRONE_SYSTEM_VERSION", f.System.Version)
+	str("DRONE_NETRC_MACHINE", f.Netrc.Machine)
+	str("DRONE_NETRC_USERNAME", f.Netrc.Username)
+	str("DRONE_NETRC_PASSWORD", f.Netrc.Password)
+
+	for k, v := range f.overrides {
+		if v == "" {
+			delete(env, k)
+			continue
+		}
+		env[k] = v
+	}
+	return env
+}
+
+var semverRe = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// semverEnv returns the DRONE_SEMVER_* variables for tag.
+func semverEnv(tag string) map[string]string {
+	m := semverRe.FindStringSubmatch(tag)
+	if m == nil {
+		return map[string]string{"DRONE_SEMVER_ERROR": fmt.Sprintf("%s is not in dotted-tri format", tag)}
+	}
+	return map[string]string{
+		"DRONE_SEMVER":            strings.TrimPrefix(tag, "v"),
+		"DRONE_SEMVER_SHORT":      m[1] + "." + m[2] + "." + m[3],
+		"DRONE_SEMVER_MAJOR":      m[1],
+		"DRONE_SEMVER_MINOR":      m[2],
+		"DRONE_SEMVER_PATCH":      m[3],
+		"DRONE_SEMVER_PRERELEASE": m[4],
+		"DRONE_SEMVER_BUILD":      m[5],
+	}
+}
+
+func (f *Fixture) before() {
+	if f.pt != nil {
+		f.pt.T.Helper()
+		f.pt.before()
+	}
+}
+
+// apply replaces the variables previously set on the test with the
+// current fixture environment.
+func (f *Fixture) apply() {
+	if f.pt == nil {
+		return
+	}
+	for _, k := range f.applied {
+		delete(f.pt.env, k)
+	}
+	f.applied = f.applied[:0]
+	for k, v := range f.Env() {
+		f.pt.env[k] = v
+		f.applied = append(f.applied, k)
+	}
+}
+
+// use applies f to the test.
+func (t *PT) use(f *Fixture) *Fixture {
+	t.T.Helper()
+	t.before()
+	f.pt = t
+	f.apply()
+	return f
+}
+
+// Push sets the drone environment for a push to branch, see the Push
+// function.
+func (t *PT) Push(branch string) *Fixture {
+	t.T.Helper()
+	return t.use(Push(branch))
+}
+
+// PullRequest sets the drone environment for pull request number from the
+// source to the target branch.
+func (t *PT) PullRequest(number int, source, target string) *Fixture {
+	t.T.Helper()
+	return t.use(PullRequest(number, source, target))
+}
+
+// Tag sets the drone environment for pushing tag.
+func (t *PT) Tag(tag string) *Fixture {
+	t.T.Helper()
+	return t.use(Tag(tag))
+}
+
+// Promote sets the drone environment for promoting a build to target.
+func (t *PT) Promote(target string) *Fixture {
+	t.T.Helper()
+	return t.use(Promote(target))
+}
+
+// Cron sets the drone environment for a build started by the cron job name.
+func (t *PT) Cron(name string) *Fixture {
+	t.T.Helper()
+	return t.use(Cron(name))
+}
@@ -0,0 +1,196 @@
+//go:build go1.18
+// +build go1.18
+
+package plugtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+// Fuzz fuzzes the settings of the plugin created by newRunner. Generated
+// values for the registered options are passed as PLUGIN_* and other env
+// vars or as command line flags to a hermetic Service with its own flag
+// set, environment, output and a FakeCommander. Fuzz checks that the plugin
+// never panics and that values which fail to parse are reported as usage
+// errors for an option. Calls to os.Exit are not intercepted, they end the
+// test process which go test reports as a failure.
+//
+// The seed corpus contains values derived from the type of each option, the
+// settings of the PT cases for the same plugin type which ran before in the
+// test binary, go test runs fuzz targets after the tests, and seeds, which
+// are setting maps like the ones given to PT.SetPluginVars:
+//
+//	func FuzzSettings(f *testing.F) {
+//		plugtest.Fuzz(f, func() plug.Runner { return &Plugin{} }, map[string]string{
+//			"token": "token",
+//		})
+//	}
+//
+// Options which read files, -env_file and the -name-file options, see
+// FlagSet.DisallowFile, are not fuzzed.
+func Fuzz(f *testing.F, newRunner func() plug.Runner, seeds ...map[string]string) {
+	f.Helper()
+	r := newRunner()
+	options := fuzzOptions(r)
+	if len(options) == 0 {
+		f.Fatal("the plugin has no options")
+	}
+	seeds = append(recordedSettings(r), seeds...)
+	for _, asFlags := range []bool{false, true} {
+		for _, seed := range seeds {
+			f.Add(encodeSeed(options, seed), asFlags)
+		}
+		for i := 0; i < 3; i++ {
+			values := make([]string, len(options))
+			for j, o := range options {
+				samples := sampleValues(o.Value)
+				values[j] = samples[i%len(samples)]
+			}
+			f.Add(strings.Join(values, "\x00"), asFlags)
+		}
+	}
+	f.Fuzz(func(t *testing.T, input string, asFlags bool) {
+		fuzzRun(t, newRunner(), options, strings.Split(input, "\x00"), asFlags)
+	})
+}
+
+// fuzzOptions returns the options of r which are fuzzed, sorted by name.
+func fuzzOptions(r plug.Runner) []plug.OptionState {
+	var options []plug.OptionState
+	for _, o := range plug.Options(r) {
+		if o.Name == "env_file" || o.FileFor != "" || len(o.Names) == 0 {
+			continue
+		}
+		options = append(options, o)
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i].Name < options[j].Name })
+	return options
+}
+
+// encodeSeed encodes the settings or env vars in seed as fuzz input.
+func encodeSeed(options []plug.OptionState, seed map[string]string) string {
+	env := make(map[string]string, 2*len(seed))
+	for k, v := range seed {
+		env[strings.ToUpper("plugin_"+k)] = v
+	}
+	for k, v := range seed {
+		env[strings.ToUpper(k)] = v // env var names take precedence
+	}
+	values := make([]string, len(options))
+	for i, o := range options {
+		for _, n := range o.Names {
+			if v, ok := env[n]; ok {
+				values[i] = v
+				break
+			}
+		}
+	}
+	return strings.Join(values, "\x00")
+}
+
+// sampleValues returns seed values for the type of an option.
+func sampleValues(v flag.Value) []string {
+	if g, ok := v.(flag.Getter); ok {
+		switch g.Get().(type) {
+		case bool:
+			return []string{"true", "false", "yes"}
+		case int, int64, uint, uint64:
+			return []string{"42", "-1", "9223372036854775808"}
+		case float64:
+			return []string{"1.5", "-0", "NaN"}
+		case string:
+			return []string{"value", "${VALUE}", "a,b"}
+		}
+		if _, ok := g.Get().(interface{ Seconds() float64 }); ok {
+			return []string{"1s", "-1h", "1"}
+		}
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice:
+		return []string{"a,b", `["a","b"]`, ",,"}
+	case reflect.Map:
+		return []string{`{"key":"value"}`, "key=value", "{"}
+	}
+	return []string{v.String(), "invalid", "{}"}
+}
+
+// fuzzRun runs r with values for options and checks the result.
+func fuzzRun(t *testing.T, r plug.Runner, options []plug.OptionState, values []string, asFlags bool) {
+	env := map[string]string{"DRONE": "true"}
+	args := []string{os.Args[0]}
+	for i, o := range options {
+		if i >= len(values) || values[i] == "" {
+			continue
+		}
+		if asFlags {
+			args = append(args, "-"+o.Name+"="+values[i])
+		} else {
+			env[o.Names[0]] = values[i]
+		}
+	}
+	var (
+		out     bytes.Buffer
+		reached bool // Exec was called
+	)
+	s := plug.NewService(
+		plug.SetFlagSet(flag.NewFlagSet("fuzz", flag.ContinueOnError)),
+		plug.SetEnvFunc(func() map[string]string { return env }),
+		plug.SetArgsFunc(func() []string { return args }),
+		plug.SetLogger(log.New(&out, "", 0)),
+		plug.SetStdin(strings.NewReader("")),
+		plug.SetStdout(&out),
+		plug.SetStderr(&out),
+		plug.SetCommander(&FakeCommander{}),
+		plug.ContinueOnError(),
+		plug.Use(func(next plug.ExecFunc) plug.ExecFunc {
+			return func(ctx context.Context, log *plug.Logger) error {
+				reached = true
+				return next(ctx, log)
+			}
+		}),
+	)
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				t.Fatalf("plugin panicked: %v\nenv: %q\nargs: %q\n%s\noutput:\n%s", v, env, args[1:], debug.Stack(), &out)
+			}
+		}()
+		s.Run(r)
+	}()
+	err := s.Err()
+	if err == nil || reached {
+		return
+	}
+	// the plugin failed before Exec, so a setting failed to parse
+	var ee *plug.ExecError
+	if !errors.As(err, &ee) {
+		t.Fatalf("unexpected error type %T: %v", err, err)
+	}
+	for name := range ee.UsageErrors {
+		if _, ok := s.Option(name); ok {
+			return
+		}
+	}
+	for _, o := range options {
+		if state, ok := s.Option(o.Name); ok && state.Err != nil {
+			return
+		}
+	}
+	t.Fatalf("parse failure not reported as a usage error for an option: %v\nenv: %q\nargs: %q\noutput:\n%s", err, env, args[1:], &out)
+}
@@ -0,0 +1,21 @@
+//go:build go1.18
+// +build go1.18
+
+package plugtest_test
+
+import (
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+	"github.com/drone-plug/drone-plugins-go/plug/plugtest"
+)
+
+func FuzzPlugin(f *testing.F) {
+	// the settings of the PT cases for Plugin are seeds as well
+	plugtest.Fuzz(f, func() plug.Runner { return &Plugin{} },
+		map[string]string{
+			"repositories": plugtest.JSON([]string{"hello"}),
+			"fork":         "koo",
+		},
+	)
+}
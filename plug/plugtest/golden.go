@@ -0,0 +1,73 @@
+package plugtest
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("plugtest.update", false, "rewrite the golden files compared by AssertGolden")
+
+// AssertGolden compares the normalized Output() with the contents of
+// filename. Volatile parts such as timestamps, temporary directories and the
+// file:line prefixes of debug logs are normalized before comparing. Run the
+// tests with -plugtest.update to rewrite the golden files.
+func (t *PT) AssertGolden(filename string) {
+	t.T.Helper()
+	t.after()
+	assertGolden(t.T, filename, normalize(t.Output()))
+}
+
+// assertGolden compares out with the contents of filename or rewrites the
+// file when the tests run with -plugtest.update.
+func assertGolden(t *testing.T, filename, out string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(out), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("%v (run the tests with -plugtest.update to create it)", err)
+	}
+	if string(data) != out {
+		t.Fatalf("output does not match %s (run the tests with -plugtest.update to rewrite it)\n got:\n%s\n expected:\n%s",
+			filename, out, data)
+	}
+}
+
+// logPrefixRe matches the date, time and file:line prefixes of log lines.
+var logPrefixRe = regexp.MustCompile(`(?m)^(\d{4}/\d{2}/\d{2} )?(\d{2}:\d{2}:\d{2}(\.\d+)? )?(([\w.-]+\.go):\d+: )?`)
+
+// normalize replaces the volatile parts of plugin output.
+func normalize(s string) string {
+	tmp := filepath.Clean(os.TempDir())
+	// the first path element below the temp dir is usually random, as are
+	// the numbered directories created by testing.T.TempDir.
+	tmpRe := regexp.MustCompile(regexp.QuoteMeta(tmp) + `/[^/\s]+(/\d{3})?`)
+	s = tmpRe.ReplaceAllString(s, "$$TMPDIR")
+	s = logPrefixRe.ReplaceAllStringFunc(s, func(prefix string) string {
+		m := logPrefixRe.FindStringSubmatch(prefix)
+		var b strings.Builder
+		if m[1] != "" {
+			b.WriteString("0000/00/00 ")
+		}
+		if m[2] != "" {
+			b.WriteString("00:00:00 ")
+		}
+		if m[4] != "" {
+			b.WriteString(m[5] + ":0: ")
+		}
+		return b.String()
+	})
+	return strings.Replace(s, "\r\n", "\n", -1)
+}
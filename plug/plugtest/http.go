@@ -0,0 +1,400 @@
+package plugtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var record = flag.Bool("plugtest.record", false, "record the cassettes used by Server.Cassette from the real upstream servers")
+
+// Request is an HTTP request received by a Server.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+func (r Request) String() string {
+	s := r.Method + " " + r.Path
+	if r.Query != "" {
+		s += "?" + r.Query
+	}
+	return s
+}
+
+// Server is an HTTP server standing in for the APIs a plugin calls, started
+// with PT.HTTPServer.
+type Server struct {
+	*httptest.Server
+
+	t        *testing.T
+	mu       sync.Mutex
+	routes   []*Route
+	requests []Request
+	secrets  []string
+	cassette *cassette
+}
+
+// Route is the scripted response for requests matching a method and path,
+// see Server.On.
+type Route struct {
+	method  string
+	path    string
+	status  int
+	header  http.Header
+	body    []byte
+	handler http.HandlerFunc
+}
+
+// Status sets the response status code, 200 by default.
+func (r *Route) Status(code int) *Route {
+	r.status = code
+	return r
+}
+
+// Header sets a response header.
+func (r *Route) Header(key, value string) *Route {
+	r.header.Set(key, value)
+	return r
+}
+
+// Body sets the response body.
+func (r *Route) Body(body string) *Route {
+	r.body = []byte(body)
+	return r
+}
+
+// JSON sets the response body to v encoded as JSON.
+func (r *Route) JSON(v interface{}) *Route {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	r.header.Set("Content-Type", "application/json")
+	r.body = data
+	return r
+}
+
+// Handler replaces the scripted response with fn.
+func (r *Route) Handler(fn http.HandlerFunc) *Route {
+	r.handler = fn
+	return r
+}
+
+// HTTPServer starts a Server which is closed when the test finishes and
+// sets the env vars names, for example PLUGIN_WEBHOOK or DOWNSTREAM_SERVER,
+// to its URL.
+func (t *PT) HTTPServer(names ...string) *Server {
+	t.T.Helper()
+	t.before()
+	s := &Server{t: t.T}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.T.Cleanup(s.Close)
+	for _, name := range names {
+		t.env[strings.ToUpper(name)] = s.URL
+	}
+	return s
+}
+
+// On registers the response for requests with method and a path matching
+// the pattern, which uses path.Match syntax. The first matching route is
+// used, requests without one get a 404 response.
+func (s *Server) On(method, pattern string) *Route {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &Route{method: method, path: pattern, status: http.StatusOK, header: make(http.Header)}
+	s.routes = append(s.routes, r)
+	return r
+}
+
+// Redact registers secret values which are replaced by REDACTED in
+// recorded cassettes and in requests matched against them.
+func (s *Server) Redact(secrets ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, secret := range secrets {
+		if secret != "" {
+			s.secrets = append(s.secrets, secret)
+		}
+	}
+}
+
+// Requests returns the requests received so far in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	var route *Route
+	for _, rt := range s.routes {
+		if ok, _ := path.Match(rt.path, req.Path); ok && (rt.method == "" || rt.method == req.Method) {
+			route = rt
+			break
+		}
+	}
+	c := s.cassette
+	s.mu.Unlock()
+
+	switch {
+	case route != nil && route.handler != nil:
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		route.handler(w, r)
+	case route != nil:
+		for k, v := range route.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(route.status)
+		_, _ = w.Write(route.body)
+	case c != nil:
+		c.serve(s, w, req)
+	default:
+		http.Error(w, fmt.Sprintf("plugtest: no route for %s", req), http.StatusNotFound)
+	}
+}
+
+// RequestCheck checks a request for Server.AssertRequest and returns a
+// description of the mismatch or an empty string.
+type RequestCheck func(r Request) string
+
+// Header checks that the request header key has value.
+func Header(key, value string) RequestCheck {
+	return func(r Request) string {
+		if got := r.Header.Get(key); got != value {
+			return fmt.Sprintf("header %s is %q, expected %q", key, got, value)
+		}
+		return ""
+	}
+}
+
+// JSONBody checks that the request body is JSON equal to v.
+func JSONBody(v interface{}) RequestCheck {
+	return func(r Request) string {
+		var got, expected interface{}
+		if err := json.Unmarshal(r.Body, &got); err != nil {
+			return fmt.Sprintf("body is not JSON: %v", err)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err.Error()
+		}
+		_ = json.Unmarshal(data, &expected)
+		if !reflect.DeepEqual(got, expected) {
+			return fmt.Sprintf("body is %s, expected %s", r.Body, data)
+		}
+		return ""
+	}
+}
+
+// AssertRequest asserts that a request with method and a path matching the
+// pattern passing all checks was received and returns it.
+func (s *Server) AssertRequest(method, pattern string, checks ...RequestCheck) Request {
+	s.t.Helper()
+	var mismatches []string
+	requests := s.Requests()
+	for _, r := range requests {
+		if ok, _ := path.Match(pattern, r.Path); !ok || r.Method != method {
+			continue
+		}
+		var failed []string
+		for _, check := range checks {
+			if msg := check(r); msg != "" {
+				failed = append(failed, msg)
+			}
+		}
+		if len(failed) == 0 {
+			return r
+		}
+		mismatches = append(mismatches, fmt.Sprintf("  %s: %s", r, strings.Join(failed, ", ")))
+	}
+	var lines []string
+	for _, r := range requests {
+		lines = append(lines, "  "+r.String())
+	}
+	if len(mismatches) > 0 {
+		s.t.Fatalf("no request %s %s passing the checks:\n%s", method, pattern, strings.Join(mismatches, "\n"))
+	}
+	s.t.Fatalf("no request %s %s, requests:\n%s", method, pattern, strings.Join(lines, "\n"))
+	return Request{}
+}
+
+// Cassette serves the requests without a route from the interactions
+// recorded in filename. When the tests run with -plugtest.record the requests are
+// instead forwarded to upstream and the interactions are written to filename
+// when the test finishes, with the values registered with Redact replaced.
+func (s *Server) Cassette(filename, upstream string) {
+	s.t.Helper()
+	c := &cassette{filename: filename, upstream: strings.TrimSuffix(upstream, "/")}
+	if *record {
+		s.t.Cleanup(func() {
+			if err := c.save(); err != nil {
+				s.t.Error(err)
+			}
+		})
+	} else {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			s.t.Fatalf("%v (run the tests with -plugtest.record to record it)", err)
+		}
+		if err := json.Unmarshal(data, &c.Interactions); err != nil {
+			s.t.Fatalf("%s: %v", filename, err)
+		}
+	}
+	s.mu.Lock()
+	s.cassette = c
+	s.mu.Unlock()
+}
+
+// cassette holds recorded HTTP interactions.
+type cassette struct {
+	filename     string
+	upstream     string
+	mu           sync.Mutex
+	Interactions []*interaction
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+	used     bool
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   string            `json:"body,omitempty"`
+}
+
+// redact replaces the secrets registered with Server.Redact in v.
+func (s *Server) redact(v string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, secret := range s.secrets {
+		v = strings.Replace(v, secret, "REDACTED", -1)
+	}
+	return v
+}
+
+func (c *cassette) serve(s *Server, w http.ResponseWriter, req Request) {
+	rec := recordedRequest{
+		Method: req.Method,
+		Path:   s.redact(req.Path),
+		Query:  s.redact(req.Query),
+		Body:   s.redact(string(req.Body)),
+	}
+	if *record {
+		c.forward(s, w, req, rec)
+		return
+	}
+	c.mu.Lock()
+	var found *interaction
+	for _, i := range c.Interactions {
+		if i.Request == rec && (found == nil || found.used && !i.used) {
+			found = i
+		}
+	}
+	if found != nil {
+		found.used = true
+	}
+	c.mu.Unlock()
+	if found == nil {
+		http.Error(w, fmt.Sprintf("plugtest: no recorded interaction for %s in %s", req, c.filename), http.StatusNotFound)
+		return
+	}
+	for k, v := range found.Response.Header {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(found.Response.Status)
+	_, _ = w.Write([]byte(found.Response.Body))
+}
+
+// forward sends req to the upstream server and records the interaction.
+func (c *cassette) forward(s *Server, w http.ResponseWriter, req Request, rec recordedRequest) {
+	u := c.upstream + req.Path
+	if req.Query != "" {
+		u += "?" + req.Query
+	}
+	out, err := http.NewRequest(req.Method, u, bytes.NewReader(req.Body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out.Header = req.Header.Clone()
+	resp, err := http.DefaultClient.Do(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	header := make(map[string]string)
+	for _, k := range []string{"Content-Type", "Location"} {
+		if v := resp.Header.Get(k); v != "" {
+			header[k] = s.redact(v)
+		}
+	}
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, &interaction{
+		Request:  rec,
+		Response: recordedResponse{Status: resp.StatusCode, Header: header, Body: s.redact(string(body))},
+	})
+	c.mu.Unlock()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(body)
+}
+
+func (c *cassette) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.MarshalIndent(c.Interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.filename, append(data, '\n'), 0644)
+}
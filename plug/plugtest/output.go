@@ -0,0 +1,50 @@
+package plugtest
+
+import (
+	"bytes"
+	"sync"
+)
+
+// output captures the log, stdout and stderr of a plugin separately and
+// combined in the order it was written.
+type output struct {
+	mu       sync.Mutex
+	combined bytes.Buffer
+	log      *outputWriter
+	stdout   *outputWriter
+	stderr   *outputWriter
+}
+
+func newOutput() *output {
+	o := &output{}
+	o.log = &outputWriter{o: o}
+	o.stdout = &outputWriter{o: o}
+	o.stderr = &outputWriter{o: o}
+	return o
+}
+
+// String returns the combined output.
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.combined.String()
+}
+
+// outputWriter is one of the captured streams of an output.
+type outputWriter struct {
+	o   *output
+	buf bytes.Buffer
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.o.mu.Lock()
+	defer w.o.mu.Unlock()
+	w.buf.Write(p)
+	return w.o.combined.Write(p)
+}
+
+func (w *outputWriter) String() string {
+	w.o.mu.Lock()
+	defer w.o.mu.Unlock()
+	return w.buf.String()
+}
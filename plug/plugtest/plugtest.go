@@ -1,10 +1,12 @@
 package plugtest
 
 import (
-	"bytes"
 	"flag"
+	"io"
 	"log"
 	"os"
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/drone-plug/drone-plugins-go/plug"
@@ -12,12 +14,21 @@ import (
 
 // T .
 type PT struct {
-	env    map[string]string
-	T      *testing.T
-	R      plug.Runner
-	logbuf *bytes.Buffer
-	Err    error // error from service.Run
-	hasRun bool
+	env     map[string]string
+	T       *testing.T
+	R       plug.Runner
+	out     *output
+	Err     error // error from service.Run
+	s       *plug.Service
+	args    []string  // command line arguments after the program name
+	stdin   io.Reader // set by SetStdin
+	workdir string    // set by SetWorkdir
+
+	commander *FakeCommander    // set by FakeCommands
+	workspace string            // set by Workspace
+	wsBefore  map[string][]byte // workspace files before Run
+	wsAfter   map[string][]byte // workspace files after Run
+	hasRun    bool
 }
 
 func New(t *testing.T, r plug.Runner) *PT {
@@ -28,10 +39,10 @@ func New(t *testing.T, r plug.Runner) *PT {
 		t.Fatal("Runner can not be nil")
 	}
 	pt := &PT{
-		env:    make(map[string]string),
-		T:      t,
-		R:      r,
-		logbuf: &bytes.Buffer{},
+		env: make(map[string]string),
+		T:   t,
+		R:   r,
+		out: newOutput(),
 	}
 	pt.SetVars(map[string]string{
 		"drone": "true",
@@ -45,24 +56,85 @@ func (t *PT) Run() error {
 		t.T.Fatal("this test has already run")
 	}
 	t.hasRun = true
-	log := log.New(t.logbuf, "", 0)
-	s := plug.NewService(
+	recordSettings(t.R, t.env)
+	log := log.New(t.out.log, "", 0)
+	args := append([]string{os.Args[0]}, t.args...)
+	opts := []plug.ServiceOption{
 		plug.SetFlagSet(flag.NewFlagSet("-", flag.ContinueOnError)),
 		plug.SetEnvFunc(t.envFunc),
-		plug.SetArgsFunc(func() []string { return []string{os.Args[0]} }),
+		plug.SetArgsFunc(func() []string { return args }),
 		plug.SetLogger(log),
+		plug.SetStdout(t.out.stdout),
+		plug.SetStderr(t.out.stderr),
 		plug.ContinueOnError(),
-	)
+	}
+	if t.stdin != nil {
+		opts = append(opts, plug.SetStdin(t.stdin))
+	}
+	if t.commander != nil {
+		opts = append(opts, plug.SetCommander(t.commander))
+	}
+	s := plug.NewService(opts...)
+	t.s = s
+	dir := t.workdir
+	if t.workspace != "" {
+		var err error
+		if t.wsBefore, err = snapshot(t.workspace); err != nil {
+			t.T.Fatal(err)
+		}
+		defer func() {
+			if t.wsAfter, err = snapshot(t.workspace); err != nil {
+				t.T.Fatal(err)
+			}
+		}()
+		if dir == "" {
+			dir = t.workspace
+		}
+	}
+	if dir != "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			t.T.Fatal(err)
+		}
+		if err := os.Chdir(dir); err != nil {
+			t.T.Fatal(err)
+		}
+		defer func() {
+			if err := os.Chdir(wd); err != nil {
+				t.T.Fatal(err)
+			}
+		}()
+	}
 	s.Run(t.R)
 	t.Err = s.Err()
 	return t.Err
 
 }
 
+// Output returns everything the plugin wrote to its log, stdout and stderr
+// in the order it was written.
 func (t *PT) Output() string {
-	// todo: document that buffer is emtpied
 	t.after()
-	return t.logbuf.String()
+	return t.out.String()
+}
+
+// Log returns what the plugin wrote to its plug.Logger, including the usage
+// output.
+func (t *PT) Log() string {
+	t.after()
+	return t.out.log.String()
+}
+
+// Stdout returns what the plugin wrote to plug.Logger.Stdout.
+func (t *PT) Stdout() string {
+	t.after()
+	return t.out.stdout.String()
+}
+
+// Stderr returns what the plugin wrote to plug.Logger.Stderr.
+func (t *PT) Stderr() string {
+	t.after()
+	return t.out.stderr.String()
 }
 
 // after ensures that Run has been called.
@@ -80,3 +152,32 @@ func (t *PT) before() {
 		t.T.Fatal("has already run")
 	}
 }
+
+// settings holds the environments of the PT cases which have run by plugin
+// type, Fuzz uses them as seeds.
+var settings struct {
+	mu   sync.Mutex
+	envs map[reflect.Type][]map[string]string
+}
+
+// recordSettings records a copy of env for the type of r.
+func recordSettings(r plug.Runner, env map[string]string) {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+	if settings.envs == nil {
+		settings.envs = make(map[reflect.Type][]map[string]string)
+	}
+	c := make(map[string]string, len(env))
+	for k, v := range env {
+		c[k] = v
+	}
+	typ := reflect.TypeOf(r)
+	settings.envs[typ] = append(settings.envs[typ], c)
+}
+
+// recordedSettings returns the environments recorded for the type of r.
+func recordedSettings(r plug.Runner) []map[string]string {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+	return settings.envs[reflect.TypeOf(r)]
+}
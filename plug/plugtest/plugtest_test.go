@@ -2,6 +2,13 @@ package plugtest_test
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -9,6 +16,10 @@ import (
 	"github.com/drone-plug/drone-plugins-go/plug/plugtest"
 )
 
+func TestMain(m *testing.M) {
+	plugtest.Main(m)
+}
+
 func TestExecFail(t *testing.T) {
 	p := &Plugin{}
 	pt := plugtest.New(t, p)
@@ -89,3 +100,408 @@ func (p *Plugin) Exec(ctx context.Context, log *plug.Logger) error {
 	log.Println("success!")
 	return nil
 }
+
+func TestGolden(t *testing.T) {
+	p := &Plugin{}
+	pt := plugtest.New(t, p)
+	pt.SetDebug()
+	pt.SetPluginVars(map[string]string{
+		"server": "server",
+		"token":  "token",
+	})
+	pt.AssertSuccess()
+	pt.AssertGolden("testdata/debug.golden")
+}
+
+func TestAssertUsageErrors(t *testing.T) {
+	pt := plugtest.New(t, &Plugin{})
+	pt.SetVars(map[string]string{
+		"downstream_server": "server",
+	})
+	pt.AssertFail()
+	pt.AssertUsageError("token", "you must provide")
+	pt.AssertOptionSetBy("server", "DOWNSTREAM_SERVER")
+	pt.AssertOptionSetBy("token", "")
+	pt.AssertExitCode(1)
+}
+
+func TestAssertOptions(t *testing.T) {
+	pt := plugtest.New(t, &Plugin{})
+	pt.SetPluginVars(map[string]string{
+		"server":       "server",
+		"token":        "token",
+		"fork":         "true",
+		"repositories": "octocat/hello,octocat/world",
+	})
+	pt.AssertNoUsageErrors()
+	pt.AssertExitCode(0)
+	pt.AssertOptionSetBy("server", "PLUGIN_SERVER")
+	pt.AssertOptionValue("server", "server")
+	pt.AssertOptionValue("fork", true)
+	pt.AssertOptionValue("repositories", []string{"octocat/hello", "octocat/world"})
+}
+
+type dronePlugin struct {
+	Drone plug.Drone
+}
+
+func (p *dronePlugin) SetFlags(fs *plug.FlagSet) {
+	fs.DroneVar(&p.Drone)
+}
+
+func (p *dronePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	return nil
+}
+
+func TestFixtures(t *testing.T) {
+	p := &dronePlugin{}
+	pt := plugtest.New(t, p)
+	pt.PullRequest(42, "feature", "main").Update(func(f *plugtest.Fixture) {
+		f.Repo.Owner = "hubot"
+	})
+	pt.AssertSuccess()
+	d := p.Drone
+	if !d.Build.IsPullRequest() || d.Commit.Ref != "refs/pull/42/head" || d.Commit.Branch != "main" ||
+		d.Commit.TargetBranch != "main" || d.Repo.Owner != "hubot" || d.Stage.Name != "default" {
+		t.Errorf("unexpected drone metadata: %+v", d)
+	}
+}
+
+func TestFixtureEnv(t *testing.T) {
+	env := plugtest.Tag("v1.2.3-rc.1").Set("drone_netrc_password", "").Env()
+	for k, v := range map[string]string{
+		"DRONE_TAG":               "v1.2.3-rc.1",
+		"DRONE_COMMIT_REF":        "refs/tags/v1.2.3-rc.1",
+		"DRONE_SEMVER":            "1.2.3-rc.1",
+		"DRONE_SEMVER_SHORT":      "1.2.3",
+		"DRONE_SEMVER_PRERELEASE": "rc.1",
+		"DRONE_REPO":              "octocat/hello-world",
+		"DRONE_SYSTEM_HOST":       "drone.example.com",
+	} {
+		if env[k] != v {
+			t.Errorf("%s: got %q, expected %q", k, env[k], v)
+		}
+	}
+	if _, ok := env["DRONE_NETRC_PASSWORD"]; ok {
+		t.Error("expected DRONE_NETRC_PASSWORD to be removed")
+	}
+	if env := plugtest.Cron("nightly").Env(); env["DRONE_CRON"] != "nightly" || env["DRONE_BUILD_EVENT"] != "cron" {
+		t.Errorf("unexpected cron env: %v", env)
+	}
+	if env := plugtest.Promote("production").Env(); env["DRONE_DEPLOY_TO"] != "production" || env["DRONE_BUILD_PARENT"] != "1" {
+		t.Errorf("unexpected promote env: %v", env)
+	}
+}
+
+func TestArgsAndEnvFile(t *testing.T) {
+	p := &Plugin{}
+	pt := plugtest.New(t, p)
+	envfile := pt.WriteEnvFile("plugin.env", map[string]string{
+		"PLUGIN_TOKEN": "token",
+	})
+	pt.SetArgs("-env_file="+envfile, "-server", "server", "-fork")
+	pt.AssertSuccess()
+	pt.AssertOptionSetBy("token", "PLUGIN_TOKEN")
+	pt.AssertOptionSetBy("server", "flag")
+	pt.AssertOptionValue("fork", true)
+}
+
+type stdinPlugin struct {
+	workdir string
+	input   string
+}
+
+func (p *stdinPlugin) SetFlags(fs *plug.FlagSet) {}
+
+func (p *stdinPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	data, err := ioutil.ReadAll(log.Stdin())
+	if err != nil {
+		return err
+	}
+	p.input = string(data)
+	p.workdir, err = os.Getwd()
+	return err
+}
+
+func TestStdinAndWorkdir(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &stdinPlugin{}
+	pt := plugtest.New(t, p)
+	pt.SetStdin(strings.NewReader("input"))
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt.SetWorkdir(dir)
+	if cwd, _ := os.Getwd(); cwd != wd {
+		t.Fatalf("working directory changed before Run: %s", cwd)
+	}
+	pt.AssertSuccess()
+	if p.input != "input" || p.workdir != dir {
+		t.Errorf("unexpected stdin or workdir: %+v", p)
+	}
+	if cwd, _ := os.Getwd(); cwd != wd {
+		t.Errorf("working directory not restored after Run: %s", cwd)
+	}
+}
+
+type printPlugin struct{}
+
+func (p *printPlugin) SetFlags(fs *plug.FlagSet) {}
+
+func (p *printPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	fmt.Fprintln(log.Stdout(), "stdout")
+	log.Println("log")
+	fmt.Fprintln(log.Stderr(), "stderr")
+	return nil
+}
+
+func TestCaptureOutput(t *testing.T) {
+	pt := plugtest.New(t, &printPlugin{})
+	pt.AssertSuccess()
+	if pt.Stdout() != "stdout\n" || pt.Stderr() != "stderr\n" || pt.Log() != "log\n" {
+		t.Errorf("unexpected output: %q %q %q", pt.Stdout(), pt.Stderr(), pt.Log())
+	}
+	pt.AssertOutput("stdout\nlog\nstderr\n")
+}
+
+type workspacePlugin struct{}
+
+func (p *workspacePlugin) SetFlags(fs *plug.FlagSet) {}
+
+func (p *workspacePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	if err := os.MkdirAll("dist", 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile("dist/out.txt", []byte("built in DRONE_WORKSPACE\n"), 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile("README.md", []byte("hello world\n"), 0644); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join("config", "app.conf"))
+}
+
+func TestWorkspace(t *testing.T) {
+	pt := plugtest.New(t, &workspacePlugin{})
+	pt.Workspace("testdata/ws")
+	pt.AssertSuccess()
+	pt.AssertFile("README.md", "hello world\n")
+	pt.AssertFileMatchesGolden("dist/out.txt", "testdata/out.golden")
+	pt.AssertNoFile("config/app.conf")
+	if c := pt.WorkspaceChanges().String(); c != "M README.md\nD config/app.conf\nA dist/out.txt" {
+		t.Errorf("unexpected workspace changes:\n%s", c)
+	}
+}
+
+type dockerPlugin struct {
+	tag string
+}
+
+func (p *dockerPlugin) SetFlags(fs *plug.FlagSet) {}
+
+func (p *dockerPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	out, err := log.Command("git", "describe", "--tags").Output(ctx)
+	if err != nil {
+		return err
+	}
+	p.tag = strings.TrimSpace(string(out))
+	if err := log.Command("docker", "build", "-t", "repo:"+p.tag, ".").Run(ctx); err != nil {
+		return err
+	}
+	return log.Command("docker", "push", "repo:"+p.tag).Run(ctx)
+}
+
+func TestFakeCommands(t *testing.T) {
+	p := &dockerPlugin{}
+	pt := plugtest.New(t, p)
+	cmds := pt.FakeCommands()
+	cmds.On("git", "describe", "...").Stdout("1.2.3\n")
+	cmds.On("docker", "*", "...")
+	pt.AssertSuccess()
+	pt.AssertCommand("docker", "push", "repo:1.2.3")
+	pt.AssertNoCommand("docker", "login", "...")
+	pt.AssertCommandOrder(
+		[]string{"git", "..."},
+		[]string{"docker", "build", "..."},
+		[]string{"docker", "push", "..."},
+	)
+	if c := cmds.Commands(); len(c) != 3 || c[1].Args[3] != "repo:1.2.3" {
+		t.Errorf("unexpected commands: %v", c)
+	}
+}
+
+func TestFakeCommandsExitCode(t *testing.T) {
+	pt := plugtest.New(t, &dockerPlugin{})
+	pt.FakeCommands().On("git", "...").Stderr("fatal: no tags\n").ExitCode(128)
+	pt.AssertFail()
+	if !strings.Contains(pt.Log(), "git describe --tags: exit status 128: fatal: no tags") {
+		t.Errorf("unexpected log:\n%s", pt.Log())
+	}
+}
+
+type pathsPlugin struct {
+	Paths plug.Constraint
+	ran   bool
+}
+
+func (p *pathsPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.PathsVar(&p.Paths)
+}
+
+func (p *pathsPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	p.ran = true
+	return nil
+}
+
+func TestFakeCommandsGit(t *testing.T) {
+	p := &pathsPlugin{}
+	pt := plugtest.New(t, p)
+	pt.SetPluginVars(map[string]string{"paths": "docs/**"})
+	pt.SetVars(map[string]string{
+		"drone_commit_sha":    "after",
+		"drone_commit_before": "before",
+	})
+	pt.FakeCommands().On("git", "diff", "...").Stdout("M\x00README.md\x00")
+	pt.AssertSuccess()
+	pt.AssertCommand("git", "diff", "--name-status", "-M", "-z", "before", "after")
+	if p.ran {
+		t.Error("expected exec to be skipped")
+	}
+}
+
+type webhookPlugin struct {
+	URL   string
+	Token string
+	Reply string
+}
+
+func (p *webhookPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.URL, "webhook", "", "webhook url")
+	fs.StringVar(&p.Token, "token", "", "webhook token")
+}
+
+func (p *webhookPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	req, err := http.NewRequest("POST", p.URL+"/hooks/build?token="+p.Token, strings.NewReader(`{"status": "success"}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook failed: %s: %s", resp.Status, data)
+	}
+	p.Reply = string(data)
+	return nil
+}
+
+func TestHTTPServer(t *testing.T) {
+	p := &webhookPlugin{}
+	pt := plugtest.New(t, p)
+	srv := pt.HTTPServer("PLUGIN_WEBHOOK")
+	srv.On("POST", "/hooks/*").JSON(map[string]bool{"ok": true})
+	pt.AssertSuccess()
+	srv.AssertRequest("POST", "/hooks/build",
+		plugtest.Header("Content-Type", "application/json"),
+		plugtest.JSONBody(map[string]string{"status": "success"}),
+	)
+	if p.Reply != `{"ok":true}` {
+		t.Errorf("unexpected reply: %s", p.Reply)
+	}
+}
+
+func TestHTTPCassette(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "token %s accepted", r.URL.Query().Get("token"))
+	}))
+	defer upstream.Close()
+	filename := filepath.Join(t.TempDir(), "webhook.json")
+
+	run := func(t *testing.T) *webhookPlugin {
+		p := &webhookPlugin{}
+		pt := plugtest.New(t, p)
+		pt.SetPluginVars(map[string]string{"token": "s3cr3t"})
+		srv := pt.HTTPServer("PLUGIN_WEBHOOK")
+		srv.Redact("s3cr3t")
+		srv.Cassette(filename, upstream.URL)
+		pt.AssertSuccess()
+		return p
+	}
+	t.Run("record", func(t *testing.T) {
+		if err := flag.Set("plugtest.record", "true"); err != nil {
+			t.Fatal(err)
+		}
+		defer flag.Set("plugtest.record", "false")
+		if p := run(t); p.Reply != "token s3cr3t accepted" {
+			t.Errorf("unexpected reply: %s", p.Reply)
+		}
+	})
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Errorf("secret was not redacted:\n%s", data)
+	}
+	upstream.Close()
+	t.Run("replay", func(t *testing.T) {
+		if p := run(t); p.Reply != "token REDACTED accepted" {
+			t.Errorf("unexpected reply: %s", p.Reply)
+		}
+	})
+}
+
+// timePlugin logs content that looks like a log prefix.
+type timePlugin struct{}
+
+func (p *timePlugin) SetFlags(fs *plug.FlagSet) {}
+
+func (p *timePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	log.Println("deploy window 12:34:56 set in deploy.go:12: done")
+	return nil
+}
+
+func TestGoldenKeepsContent(t *testing.T) {
+	pt := plugtest.New(t, &timePlugin{})
+	pt.AssertSuccess()
+	pt.AssertGolden("testdata/content.golden")
+}
+
+// usageNilPlugin registers an usage error but returns nil.
+type usageNilPlugin struct {
+	Token string
+}
+
+func (p *usageNilPlugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Token, "token", "", "Drone API token")
+}
+
+func (p *usageNilPlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	if p.Token == "" {
+		log.Usagef(&p.Token, "you must provide a token")
+	}
+	return nil
+}
+
+func TestExitCodeUsageNil(t *testing.T) {
+	pt := plugtest.New(t, &usageNilPlugin{})
+	pt.AssertUsageError("token", "you must provide a token")
+	pt.AssertExitCode(0)
+
+	if testing.Short() {
+		t.Skip("compiles the plugin")
+	}
+	b := plugtest.Binary(t, "./testdata/usagenil")
+	b.AssertExitCode(0)
+}
@@ -0,0 +1,29 @@
+// Command usagenil registers an usage error but returns nil from Exec, the
+// process exits with 0.
+package main
+
+import (
+	"context"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+type Plugin struct {
+	Token string
+}
+
+func (p *Plugin) SetFlags(fs *plug.FlagSet) {
+	fs.StringVar(&p.Token, "token", "", "Drone API token")
+}
+
+func (p *Plugin) Exec(ctx context.Context, log *plug.Logger) error {
+	if p.Token == "" {
+		log.Usagef(&p.Token, "you must provide a token")
+	}
+	return nil
+}
+
+func main() {
+	var p Plugin
+	plug.Run(&p)
+}
@@ -0,0 +1,177 @@
+package plugtest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Workspace creates a temporary workspace directory which is removed when
+// the test finishes, copies the fixture tree src into it unless src is
+// empty and sets DRONE_WORKSPACE. The workspace is the working directory
+// while the plugin runs unless SetWorkdir is used and is snapshotted before
+// and after, see
+// WorkspaceChanges. Returns the workspace directory.
+func (t *PT) Workspace(src string) string {
+	t.T.Helper()
+	t.before()
+	dir, err := filepath.EvalSymlinks(t.T.TempDir())
+	if err != nil {
+		t.T.Fatal(err)
+	}
+	if src != "" {
+		if err := copyTree(src, dir); err != nil {
+			t.T.Fatal(err)
+		}
+	}
+	t.workspace = dir
+	t.env["DRONE_WORKSPACE"] = dir
+	return dir
+}
+
+// WorkspaceChanges lists the files changed in the workspace by the plugin.
+type WorkspaceChanges struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+func (c WorkspaceChanges) String() string {
+	var lines []string
+	for _, f := range c.Added {
+		lines = append(lines, "A "+f)
+	}
+	for _, f := range c.Modified {
+		lines = append(lines, "M "+f)
+	}
+	for _, f := range c.Removed {
+		lines = append(lines, "D "+f)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	return strings.Join(lines, "\n")
+}
+
+// WorkspaceChanges returns the files added, modified and removed in the
+// workspace while the plugin ran, with slash separated paths relative to the
+// workspace.
+func (t *PT) WorkspaceChanges() WorkspaceChanges {
+	t.T.Helper()
+	t.requireWorkspace()
+	t.after()
+	var c WorkspaceChanges
+	for name, data := range t.wsAfter {
+		before, ok := t.wsBefore[name]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, name)
+		case !bytes.Equal(before, data):
+			c.Modified = append(c.Modified, name)
+		}
+	}
+	for name := range t.wsBefore {
+		if _, ok := t.wsAfter[name]; !ok {
+			c.Removed = append(c.Removed, name)
+		}
+	}
+	sort.Strings(c.Added)
+	sort.Strings(c.Modified)
+	sort.Strings(c.Removed)
+	return c
+}
+
+// AssertFile asserts that the file at path in the workspace has content.
+func (t *PT) AssertFile(path, content string) {
+	t.T.Helper()
+	data := t.readFile(path)
+	if string(data) != content {
+		t.T.Fatalf("file %s not as expected!\n got:\n%s\n expected:\n%s", path, data, content)
+	}
+}
+
+// AssertFileMatchesGolden compares the file at path in the workspace byte
+// for byte with the golden file. Unlike AssertGolden nothing is normalized.
+func (t *PT) AssertFileMatchesGolden(path, golden string) {
+	t.T.Helper()
+	assertGolden(t.T, golden, string(t.readFile(path)))
+}
+
+// AssertNoFile asserts that there is no file at path in the workspace.
+func (t *PT) AssertNoFile(path string) {
+	t.T.Helper()
+	t.requireWorkspace()
+	t.after()
+	if _, err := os.Lstat(filepath.Join(t.workspace, filepath.FromSlash(path))); err == nil {
+		t.T.Fatalf("file %s should not exist, workspace changes:\n%s", path, t.WorkspaceChanges())
+	} else if !os.IsNotExist(err) {
+		t.T.Fatal(err)
+	}
+}
+
+func (t *PT) readFile(path string) []byte {
+	t.T.Helper()
+	t.requireWorkspace()
+	t.after()
+	data, err := ioutil.ReadFile(filepath.Join(t.workspace, filepath.FromSlash(path)))
+	if err != nil {
+		t.T.Fatalf("%v, workspace changes:\n%s", err, t.WorkspaceChanges())
+	}
+	return data
+}
+
+func (t *PT) requireWorkspace() {
+	t.T.Helper()
+	if t.workspace == "" {
+		t.T.Fatal("no workspace, call Workspace before running the plugin")
+	}
+}
+
+// snapshot returns the contents of the regular files below dir by slash
+// separated relative path.
+func snapshot(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	return files, err
+}
+
+// copyTree copies the files and directories below src into dst.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case info.Mode().IsRegular():
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(target, data, info.Mode().Perm())
+		}
+		return fmt.Errorf("can not copy %s: not a regular file", path)
+	})
+}
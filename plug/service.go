@@ -4,9 +4,14 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/go-pa/fenv"
 	"github.com/joho/godotenv"
@@ -30,12 +35,24 @@ type Service struct {
 	fs              *flag.FlagSet
 	es              *fenv.EnvSet        // fenv.Envset for fs
 	pfs             *FlagSet            // FlagSet for fs
-	usageErrors     map[string][]string // errors registerd by logger
+	usageErrors     map[string][]string // errors registerd by logger, global errors use the empty key
+	warnings        map[string][]string // warnings registerd by logger, global warnings use the empty key
 	log             *Logger
-	debug           bool  // plugin debug mode
-	asPlugin        bool  //true when DRONE=true (environment is drone), swithces display
-	continueOnError bool  // if set to true the process does not exit on usage or command error
-	execErr         error // the error which can be retreived using the Err() method if  continueOnError after Run if continueOnError is enabled.
+	debug           bool                // plugin debug mode
+	asPlugin        bool                //true when DRONE=true (environment is drone), swithces display
+	continueOnError bool                // if set to true the process does not exit on usage or command error
+	execErr         error               // the error which can be retreived using the Err() method if  continueOnError after Run if continueOnError is enabled.
+	outputs         map[string]string   // output variables set by the plugin
+	middleware      []Middleware        // middleware installed around Runner.Exec
+	mu              sync.Mutex          // guards usageErrors, warnings and outputs
+	setBy           map[string]string   // describes how flags were set when not by their own env vars or flags
+	aliases         map[string][]string // alias setting names by flag name
+	aliasValues     map[string]string   // raw values of options set by an alias
+	fileFlags       map[string]string   // flag names for -name-file flags
+	stdin           io.Reader           // returned by Logger.Stdin
+	stdout          io.Writer           // returned by Logger.Stdout
+	stderr          io.Writer           // returned by Logger.Stderr
+	commander       Commander           // runs commands created with Logger.Command
 
 }
 
@@ -88,6 +105,39 @@ func SetLogger(l *log.Logger) ServiceOption {
 	}
 }
 
+// SetStdin is a NewService option to set the reader returned by
+// Logger.Stdin instead of os.Stdin.
+func SetStdin(r io.Reader) ServiceOption {
+	if r == nil {
+		log.Fatal("Stdin is nil")
+	}
+	return func(s *Service) {
+		s.stdin = r
+	}
+}
+
+// SetStdout is a NewService option to set the writer returned by
+// Logger.Stdout instead of os.Stdout.
+func SetStdout(w io.Writer) ServiceOption {
+	if w == nil {
+		log.Fatal("Stdout is nil")
+	}
+	return func(s *Service) {
+		s.stdout = w
+	}
+}
+
+// SetStderr is a NewService option to set the writer returned by
+// Logger.Stderr instead of os.Stderr.
+func SetStderr(w io.Writer) ServiceOption {
+	if w == nil {
+		log.Fatal("Stderr is nil")
+	}
+	return func(s *Service) {
+		s.stderr = w
+	}
+}
+
 func ContinueOnError() ServiceOption {
 	return func(s *Service) {
 		s.continueOnError = true
@@ -108,10 +158,18 @@ func NewService(opts ...ServiceOption) *Service {
 
 /// Run runs the service
 func (s *Service) Run(r Runner) {
+	s.RunContext(context.Background(), r)
+}
+
+// RunContext runs the service with ctx passed on to Runner.Exec.
+func (s *Service) RunContext(ctx context.Context, r Runner) {
 	s.init()
 	env := s.envFunc()
 	pfs := &FlagSet{FlagSet: s.fs, es: s.es}
+	s.pfs = pfs
 	r.SetFlags(pfs)
+	s.registerFileFlags()
+	s.registerAliases()
 
 	s.asPlugin = env["DRONE"] == "true"
 	s.debug = env["PLUGIN_PLUGIN_DEBUG"] != ""
@@ -130,11 +188,16 @@ func (s *Service) Run(r Runner) {
 		s.log.Debugln("drone plugins debug mode is active!")
 	}
 	if s.debug {
-		for k, v := range env {
+		var keys []string
+		for k := range env {
 			if strings.HasPrefix(k, "PLUGIN_") || strings.HasPrefix(k, "DRONE_") {
-				s.log.Debugf("[env] %s=%s", k, v)
+				keys = append(keys, k)
 			}
 		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s.log.Debugf("[env] %s=%s", k, env[k])
+		}
 		s.es.VisitAll(func(e fenv.EnvFlag) {
 			s.log.Debugf("[assign] flag '%s' for env vars: %s",
 				e.Flag.Name, strings.Join(e.Names, ", "))
@@ -160,15 +223,43 @@ func (s *Service) Run(r Runner) {
 
 	}
 
-	if err := s.fs.Parse(s.args()[1:]); err != nil {
+	if !s.parseAliases(env) || !s.parseInterpolation(env) {
+		s.execErr = ErrUsageError
+		s.fs.Usage()
+		if !s.continueOnError {
+			os.Exit(1)
+		}
+		return
+	}
+
+	aliased := s.aliasedValues()
+	usage := s.fs.Usage
+	s.fs.Usage = func() {} // shown below when the error is registered
+	err := s.fs.Parse(s.args()[1:])
+	s.fs.Usage = usage
+	if err != nil {
 		s.execErr = err
-		s.log.Println(err)
+		if err != flag.ErrHelp {
+			s.addUsageError(s.parseErrorFlag(err), err.Error())
+			s.log.Println(err)
+		}
+		s.fs.Usage()
 		if !s.continueOnError {
 			os.Exit(1)
 		}
 		return
 
 	}
+	s.parseGit(ctx, env)
+	if !s.checkAliasFlags(aliased) || !s.parseFiles() || !s.parseDefaults(env) {
+		s.execErr = ErrUsageError
+		s.fs.Usage()
+		if !s.continueOnError {
+			os.Exit(1)
+		}
+		return
+	}
+	s.checkDeprecated()
 	if s.debug {
 		s.es.VisitAll(func(e fenv.EnvFlag) {
 			if !e.IsSelfSet && e.IsSet {
@@ -182,9 +273,16 @@ func (s *Service) Run(r Runner) {
 		})
 		s.usageFuncYml()
 	}
-	ctx := context.Background()
 	s.log.Debugln("------ executing plugin func  -----")
-	err := r.Exec(ctx, s.log)
+	exec := ExecFunc(r.Exec)
+	if mr, ok := r.(MiddlewareRunner); ok {
+		exec = chain(exec, mr.Middleware()...)
+	}
+	exec = chain(exec, s.middleware...)
+	if pfs.paths != nil {
+		exec = chain(exec, s.skipUnchanged(env))
+	}
+	err = exec(ctx, s.log)
 	s.log.Debugln("------ plugin func done  -----")
 	s.execErr = err
 	var hasErrors bool
@@ -210,6 +308,24 @@ func (s *Service) Run(r Runner) {
 		}
 		return
 	}
+	s.warningsUsage()
+	if err != nil {
+		s.log.Println(fmtErrorReport(err))
+		if !s.continueOnError {
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+	if filename := env["DRONE_OUTPUT"]; filename != "" && len(s.outputs) > 0 {
+		s.log.Debugf("[output] writing output variables to %s", filename)
+		if err := godotenv.Write(s.outputs, filename); err != nil {
+			s.execErr = err
+			s.log.Println(err)
+			if !s.continueOnError {
+				os.Exit(1)
+			}
+		}
+	}
 }
 
 // init various internal variables and sets default values
@@ -219,6 +335,12 @@ func (s *Service) init() {
 	}
 	s.hasInit = true
 	s.usageErrors = make(map[string][]string)
+	s.warnings = make(map[string][]string)
+	s.setBy = make(map[string]string)
+	s.aliases = make(map[string][]string)
+	s.aliasValues = make(map[string]string)
+	s.fileFlags = make(map[string]string)
+	s.outputs = make(map[string]string)
 	if s.fs == nil {
 		s.fs = flag.CommandLine
 	}
@@ -242,26 +364,30 @@ func (s *Service) readEnvfiles(env map[string]string, defaultValue []string) {
 	fs := flag.NewFlagSet("envfile", flag.ContinueOnError)
 
 	es := fenv.NewEnvSet(fs, fenv.Prefix("plugin_"), fenv.ContinueOnError())
-	envfiles = append(envfiles)
 	fs.Var((*stringSliceFlag)(&envfiles), envfileFlagName, "source env file")
 	if err := es.ParseEnv(env); err != nil {
 		s.log.Fatal(err)
 	}
 
 	{
+		// pick the -env_file flags out of the command line, the other flags
+		// are parsed later.
 		var args []string
+		argv := s.args()[1:]
 	loop:
-		for _, arg := range s.args()[1:] {
-			if len(args) == 0 && arg == "-"+envfileFlagName {
-				args = append(args, arg)
-				continue loop
-			}
-
-			if len(args) == 1 {
-				args = append(args, arg)
+		for i := 0; i < len(argv); i++ {
+			arg := argv[i]
+			switch {
+			case arg == "--":
 				break loop
+			case arg == "-"+envfileFlagName || arg == "--"+envfileFlagName:
+				if i+1 < len(argv) {
+					args = append(args, arg, argv[i+1])
+					i++
+				}
+			case strings.HasPrefix(arg, "-"+envfileFlagName+"="), strings.HasPrefix(arg, "--"+envfileFlagName+"="):
+				args = append(args, arg)
 			}
-
 		}
 		if err := fs.Parse(args); err != nil {
 			s.log.Fatal(err)
@@ -301,13 +427,205 @@ func (s *Service) parse() error {
 	return nil
 }
 
+// parseErrorFlagRe matches the flag name in the errors of flag.FlagSet.Parse:
+//
+//	flag provided but not defined: -name
+//	flag needs an argument: -name
+//	invalid value "x" for flag -name: parse error
+//	invalid boolean value "x" for -name: parse error
+var parseErrorFlagRe = regexp.MustCompile(`(?:for flag|for|argument:|defined:) --?([^\s:]+)`)
+
+// parseErrorFlag returns the name of the flag a flag.FlagSet parse error is
+// about or an empty string if it is not about a defined flag.
+func (s *Service) parseErrorFlag(err error) string {
+	m := parseErrorFlagRe.FindStringSubmatch(err.Error())
+	if m == nil || s.fs.Lookup(m[1]) == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// checkDeprecated registers warnings for deprecated options which are set.
+func (s *Service) checkDeprecated() {
+	for _, d := range s.pfs.deprecated {
+		flg, err := s.findEnvFlag(d.ref)
+		if err != nil {
+			s.log.programmingFatalf("Deprecated: %v", err)
+		}
+		if flg.IsSet {
+			s.addWarning(flg.Flag.Name, "deprecated: "+d.msg)
+		}
+	}
+}
+
+// addUsageError registers an usage error for the flag name or a global usage
+// error if name is empty.
+func (s *Service) addUsageError(name, msg string) {
+	s.mu.Lock()
+	s.usageErrors[name] = append(s.usageErrors[name], msg)
+	s.mu.Unlock()
+}
+
+// addWarning registers a warning for the flag name or a global warning if
+// name is empty.
+func (s *Service) addWarning(name, msg string) {
+	s.mu.Lock()
+	s.warnings[name] = append(s.warnings[name], msg)
+	s.mu.Unlock()
+}
+
+// findEnvFlag returns the flag bound to ref.
+func (s *Service) findEnvFlag(ref interface{}) (*fenv.EnvFlag, error) {
+	rv := reflect.ValueOf(ref)
+	if rv.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("not a pointer: %v", ref)
+	}
+	vp := rv.Pointer()
+	var flg *fenv.EnvFlag
+	s.es.VisitAll(func(f fenv.EnvFlag) {
+		p := reflect.ValueOf(f.Flag.Value).Pointer()
+		if vp == p {
+			flg = &f
+		}
+	})
+	if flg == nil {
+		return nil, fmt.Errorf("no flag is bound to %T", ref)
+	}
+	return flg, nil
+}
+
+// OptionState describes a plugin option after a run.
+type OptionState struct {
+	Name    string     // flag name
+	Names   []string   // env var names
+	Aliases []string   // alias setting names registered by FlagSet.Alias
+	FileFor string     // option read from the file given by this flag, see FlagSet.DisallowFile
+	Value   flag.Value // the flag value
+	SetBy   string     // env var name, "flag" or the set by description from the usage output, empty when unset
+	Err     error      // error from setting the option from its env var
+}
+
+// Option returns the state of the option with the flag name.
+func (s *Service) Option(name string) (OptionState, bool) {
+	var (
+		state OptionState
+		ok    bool
+	)
+	s.es.VisitAll(func(e fenv.EnvFlag) {
+		if e.Flag.Name != name {
+			return
+		}
+		ok = true
+		state = OptionState{
+			Name:    name,
+			Names:   e.Names,
+			Aliases: s.aliases[name],
+			FileFor: s.fileFlags[name],
+			Value:   e.Flag.Value,
+			Err:     e.Err,
+		}
+		switch {
+		case s.setBy[name] != "":
+			state.SetBy = s.setBy[name]
+		case e.IsSelfSet:
+			state.SetBy = e.Name
+		case e.IsSet:
+			state.SetBy = "flag"
+		}
+	})
+	return state, ok
+}
+
+// Options returns the options registered by r without running it, for
+// example to generate documentation for the settings of a plugin. The values
+// are the defaults.
+func Options(r Runner) []OptionState {
+	s := NewService(SetFlagSet(flag.NewFlagSet("options", flag.ContinueOnError)))
+	s.init()
+	s.pfs = &FlagSet{FlagSet: s.fs, es: s.es}
+	r.SetFlags(s.pfs)
+	s.registerFileFlags()
+	s.registerAliases()
+	var options []OptionState
+	s.es.VisitAll(func(e fenv.EnvFlag) {
+		options = append(options, OptionState{
+			Name:    e.Flag.Name,
+			Names:   e.Names,
+			Aliases: s.aliases[e.Flag.Name],
+			FileFor: s.fileFlags[e.Flag.Name],
+			Value:   e.Flag.Value,
+		})
+	})
+	return options
+}
+
+// Warnings returns a copy of the warnings registered by the plugin. Global
+// warnings use the empty key.
+func (s *Service) Warnings() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyMessages(s.warnings)
+}
+
+// Outputs returns a copy of the output variables set by the plugin.
+func (s *Service) Outputs() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyOutputs(s.outputs)
+}
+
+// execState holds what the plugin registers while Exec runs.
+type execState struct {
+	usageErrors map[string][]string
+	warnings    map[string][]string
+	outputs     map[string]string
+}
+
+// saveState returns a copy of the usage errors, warnings and outputs.
+func (s *Service) saveState() execState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return execState{
+		usageErrors: copyMessages(s.usageErrors),
+		warnings:    copyMessages(s.warnings),
+		outputs:     copyOutputs(s.outputs),
+	}
+}
+
+// restoreState drops everything registered after st was saved.
+func (s *Service) restoreState(st execState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usageErrors = copyMessages(st.usageErrors)
+	s.warnings = copyMessages(st.warnings)
+	s.outputs = copyOutputs(st.outputs)
+}
+
+func copyMessages(m map[string][]string) map[string][]string {
+	c := make(map[string][]string, len(m))
+	for k, v := range m {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+func copyOutputs(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
 // Err returns an *ExitError struct consisting of various error information
 func (s *Service) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.execErr == nil && len(s.usageErrors) == 0 {
 		return nil
 	}
 	return &ExecError{
 		Err:         s.execErr,
-		UsageErrors: s.usageErrors,
+		UsageErrors: copyMessages(s.usageErrors),
 	}
 }
@@ -0,0 +1,104 @@
+package plug
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Settings holds plugin settings as they would be written in the settings
+// section of a .drone.yml step.
+type Settings map[string]interface{}
+
+// Env returns the settings encoded as PLUGIN_* environment variables the same
+// way the drone runner encodes them. Settings which can not be JSON encoded
+// are omitted, Invoke reports them as errors.
+func (s Settings) Env() map[string]string {
+	env, _ := s.env()
+	return env
+}
+
+// env is like Env but returns an error for the first setting by name which
+// can not be encoded.
+func (s Settings) env() (map[string]string, error) {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var firstErr error
+	env := make(map[string]string, len(s))
+	for _, k := range keys {
+		value, err := encodeSetting(s[k])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("setting %s: %v", k, err)
+			}
+			continue
+		}
+		env[settingEnvName(k)] = value
+	}
+	return env, firstErr
+}
+
+// settingEnvName returns the PLUGIN_* environment variable name for a setting.
+func settingEnvName(name string) string {
+	s := "plugin_" + name
+	s = strings.Replace(s, ".", "_", -1)
+	s = strings.Replace(s, "-", "_", -1)
+	return strings.ToUpper(s)
+}
+
+// encodeSetting encodes a setting value: scalars are formatted as strings,
+// slices of scalars are comma separated and everything else is JSON encoded.
+func encodeSetting(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	}
+	rv := reflect.ValueOf(v)
+	if isScalarKind(rv.Kind()) {
+		return fmt.Sprint(v), nil
+	}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		var values []string
+		for i := 0; i < rv.Len(); i++ {
+			ev := rv.Index(i)
+			for ev.Kind() == reflect.Interface && !ev.IsNil() {
+				ev = ev.Elem()
+			}
+			if !isScalarKind(ev.Kind()) {
+				return encodeJSONSetting(v)
+			}
+			values = append(values, fmt.Sprint(ev.Interface()))
+		}
+		return strings.Join(values, ","), nil
+	}
+	return encodeJSONSetting(v)
+}
+
+func encodeJSONSetting(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package template
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// goBuiltins are the text/template functions which may be called from
+// Handlebars templates.
+var goBuiltins = map[string]bool{
+	"and": true, "or": true, "not": true, "len": true, "index": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"printf": true,
+}
+
+// goKeywords are the text/template actions which mark a template as Go syntax.
+var goKeywords = []string{"if ", "range ", "with ", "end", "define ", "template ", "block "}
+
+// detect returns the syntax of text, see Auto.
+func detect(text string) Syntax {
+	for _, action := range actions(text) {
+		a := strings.TrimSpace(strings.Trim(action, "{}"))
+		a = strings.TrimSpace(strings.TrimPrefix(strings.TrimSuffix(a, "-"), "-"))
+		switch {
+		case a == "":
+		case strings.HasPrefix(a, "#"), strings.HasPrefix(a, "/"), strings.HasPrefix(a, "!"),
+			strings.HasPrefix(a, "~"), strings.HasPrefix(action, "{{{"):
+			return Handlebars
+		case strings.HasPrefix(a, "."), strings.HasPrefix(a, "$"):
+			return Go
+		default:
+			for _, k := range goKeywords {
+				if strings.HasPrefix(a, k) {
+					return Go
+				}
+			}
+		}
+	}
+	return Handlebars
+}
+
+// actions returns the {{...}} actions in text.
+func actions(text string) []string {
+	var res []string
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			return res
+		}
+		end := strings.Index(text[start:], "}}")
+		if end < 0 {
+			return res
+		}
+		end += start + 2
+		if strings.HasPrefix(text[start:], "{{{") && strings.HasPrefix(text[end:], "}") {
+			end++
+		}
+		res = append(res, text[start:end])
+		text = text[end:]
+	}
+}
+
+// translate converts a Handlebars template to text/template syntax. The
+// supported subset covers what notification plugin templates use: paths,
+// helper calls with subexpressions, comments, whitespace control and the
+// if, unless, each and with block helpers as well as boolean helpers such
+// as success and failure used as blocks.
+func translate(text string) (string, error) {
+	var (
+		b     strings.Builder
+		stack []string
+	)
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			b.WriteString(text)
+			break
+		}
+		b.WriteString(text[:start])
+		text = text[start:]
+		open, closing := "{{", "}}"
+		if strings.HasPrefix(text, "{{{") {
+			open, closing = "{{{", "}}}"
+		}
+		if strings.HasPrefix(text, "{{!--") {
+			open, closing = "{{!--", "--}}"
+		}
+		end := strings.Index(text[len(open):], closing)
+		if end < 0 {
+			return "", fmt.Errorf("unclosed action: %s", firstLine(text))
+		}
+		body := text[len(open) : len(open)+end]
+		text = text[len(open)+end+len(closing):]
+		if open == "{{!--" {
+			continue
+		}
+
+		lt, rt := "{{", "}}"
+		if strings.HasPrefix(body, "~") {
+			lt, body = "{{- ", body[1:]
+		}
+		if strings.HasSuffix(body, "~") {
+			rt, body = " -}}", body[:len(body)-1]
+		}
+		body = strings.TrimSpace(body)
+
+		switch {
+		case strings.HasPrefix(body, "!"):
+			continue
+		case strings.HasPrefix(body, "#"):
+			fields := strings.Fields(body[1:])
+			if len(fields) == 0 {
+				return "", fmt.Errorf("missing block helper name: {{%s}}", body)
+			}
+			name := fields[0]
+			expr, err := translateExpr(strings.TrimSpace(body[1+len(name):]), false)
+			if err != nil {
+				return "", err
+			}
+			if expr == "" {
+				return "", fmt.Errorf("missing block helper argument: {{%s}}", body)
+			}
+			stack = append(stack, name)
+			switch name {
+			case "if":
+				b.WriteString(lt + "if " + expr + rt)
+			case "unless":
+				b.WriteString(lt + "if not (" + expr + ")" + rt)
+			case "each":
+				b.WriteString(lt + "range $index, $element := " + expr + rt)
+			case "with":
+				b.WriteString(lt + "with " + expr + rt)
+			default:
+				if _, ok := Funcs[name]; !ok {
+					return "", fmt.Errorf("unknown block helper: %s", name)
+				}
+				b.WriteString(lt + "if " + name + " " + expr + rt)
+			}
+		case strings.HasPrefix(body, "/"):
+			name := strings.TrimSpace(body[1:])
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return "", fmt.Errorf("unexpected closing block: {{/%s}}", name)
+			}
+			stack = stack[:len(stack)-1]
+			b.WriteString(lt + "end" + rt)
+		case body == "else" || body == "^":
+			if len(stack) == 0 {
+				return "", fmt.Errorf("{{else}} outside of a block")
+			}
+			b.WriteString(lt + "else" + rt)
+		default:
+			expr, err := translateExpr(body, true)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(lt + expr + rt)
+		}
+	}
+	if len(stack) > 0 {
+		return "", fmt.Errorf("unclosed block: {{#%s}}", stack[len(stack)-1])
+	}
+	return b.String(), nil
+}
+
+// translateExpr converts a Handlebars expression. If call is set the first
+// token is treated as a helper name when it names a function and arguments
+// follow it.
+func translateExpr(expr string, call bool) (string, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return "", err
+	}
+	var out []string
+	for i, tok := range tokens {
+		if i == 0 && call && len(tokens) > 1 {
+			if _, ok := Funcs[tok]; ok || goBuiltins[tok] {
+				out = append(out, tok)
+				continue
+			}
+		}
+		t, err := translateToken(tok)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, t)
+	}
+	return strings.Join(out, " "), nil
+}
+
+// translateToken converts a single argument.
+func translateToken(tok string) (string, error) {
+	switch {
+	case strings.HasPrefix(tok, "("):
+		inner, err := translateExpr(tok[1:len(tok)-1], true)
+		if err != nil {
+			return "", err
+		}
+		return "(" + inner + ")", nil
+	case strings.HasPrefix(tok, `"`):
+		return tok, nil
+	case strings.HasPrefix(tok, "'"):
+		return strconv.Quote(tok[1 : len(tok)-1]), nil
+	case tok == "true" || tok == "false":
+		return tok, nil
+	case tok == "this":
+		return ".", nil
+	case strings.HasPrefix(tok, "this."):
+		return tok[len("this"):], nil
+	case tok == "@index" || tok == "@key":
+		return "$index", nil
+	case strings.HasPrefix(tok, "../"):
+		return "$." + strings.TrimLeft(tok, "./"), nil
+	case strings.Contains(tok, "="):
+		return "", fmt.Errorf("hash arguments are not supported: %s", tok)
+	}
+	if _, err := strconv.ParseFloat(tok, 64); err == nil {
+		return tok, nil
+	}
+	return "." + tok, nil
+}
+
+// tokenize splits an expression on spaces, keeping quoted strings and
+// parenthesized subexpressions together.
+func tokenize(expr string) ([]string, error) {
+	var (
+		tokens []string
+		cur    strings.Builder
+		depth  int
+		quote  byte
+	)
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			cur.WriteByte(c)
+			if c == '\\' && i+1 < len(expr) {
+				i++
+				cur.WriteByte(expr[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			cur.WriteByte(c)
+		case c == '(':
+			depth++
+			cur.WriteByte(c)
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses: %s", expr)
+			}
+			cur.WriteByte(c)
+		case (c == ' ' || c == '\t' || c == '\n') && depth == 0:
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return nil, fmt.Errorf("unterminated expression: %s", expr)
+	}
+	flush()
+	return tokens, nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
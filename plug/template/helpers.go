@@ -0,0 +1,116 @@
+package template
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	gotemplate "text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// Funcs are the helper functions available in templates.
+var Funcs = gotemplate.FuncMap{
+	"truncate":       truncate,
+	"shortsha":       shortSha,
+	"duration":       duration,
+	"since":          since,
+	"datetime":       datetime,
+	"uppercase":      strings.ToUpper,
+	"lowercase":      strings.ToLower,
+	"uppercasefirst": uppercaseFirst,
+	"regexReplace":   regexReplace,
+	"urlencode":      url.QueryEscape,
+	"success":        success,
+	"failure":        failure,
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if n < 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// shortSha returns the first 8 characters of a commit sha.
+func shortSha(sha string) string {
+	return truncate(sha, 8)
+}
+
+// duration returns the time between two unix timestamps, using now if end
+// is not set.
+func duration(start, end interface{}) string {
+	s, e := toInt64(start), toInt64(end)
+	if s <= 0 {
+		return "0s"
+	}
+	if e <= 0 {
+		e = time.Now().Unix()
+	}
+	return (time.Duration(e-s) * time.Second).String()
+}
+
+// since returns the time since a unix timestamp.
+func since(start interface{}) string {
+	return duration(start, 0)
+}
+
+// datetime formats a unix timestamp with a Go time layout in the named
+// location, which defaults to UTC.
+func datetime(ts interface{}, layout string, zone ...string) (string, error) {
+	t := time.Unix(toInt64(ts), 0).UTC()
+	if len(zone) > 0 && zone[0] != "" {
+		loc, err := time.LoadLocation(zone[0])
+		if err != nil {
+			return "", err
+		}
+		t = t.In(loc)
+	}
+	return t.Format(layout), nil
+}
+
+func uppercaseFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return strings.ToUpper(string(r)) + s[size:]
+}
+
+// regexReplace replaces matches of pattern in input, with the same argument
+// order as drone-slack.
+func regexReplace(pattern, input, replacement string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(input, replacement), nil
+}
+
+func success(status interface{}) bool {
+	return fmt.Sprint(status) == "success"
+}
+
+func failure(status interface{}) bool {
+	return fmt.Sprint(status) == "failure"
+}
+
+func toInt64(v interface{}) int64 {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float())
+	case reflect.String:
+		n, _ := strconv.ParseInt(rv.String(), 10, 64)
+		return n
+	}
+	return 0
+}
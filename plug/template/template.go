@@ -0,0 +1,300 @@
+// Package template renders user supplied message templates against drone
+// build metadata, as used by notification plugins.
+//
+// Templates are written either in Go text/template syntax or in the
+// Handlebars syntax used by drone-slack and similar plugins:
+//
+//	{{#success build.status}}
+//	  build {{build.number}} succeeded in {{duration build.started build.finished}}
+//	{{else}}
+//	  build {{build.number}} of {{repo.owner}}/{{repo.name}} failed
+//	{{/success}}
+//
+// The same template in Go syntax:
+//
+//	{{if success .build.status}}
+//	  build {{.build.number}} succeeded in {{duration .build.started .build.finished}}
+//	{{else}}
+//	  build {{.build.number}} of {{.repo.owner}}/{{.repo.name}} failed
+//	{{end}}
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	gotemplate "text/template"
+	"text/template/parse"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+)
+
+// Syntax is the syntax of a template.
+type Syntax int
+
+// Template syntaxes.
+const (
+	Auto       Syntax = iota // Handlebars if the template uses block helpers or unprefixed paths, otherwise Go
+	Go                       // Go text/template syntax
+	Handlebars               // Handlebars syntax
+)
+
+// FilePrefix is the prefix of template values which are loaded from a file.
+const FilePrefix = "file://"
+
+// Template is a parsed message template. It implements flag.Value so that it
+// can be registered as a plugin option with FlagSet.Var, in which case
+// invalid templates are reported as errors for that option.
+type Template struct {
+	Syntax Syntax // syntax used when parsing with Set, Auto by default
+
+	source string // the value given to Set
+	text   string // the template text
+	tmpl   *gotemplate.Template
+}
+
+// Parse parses text in the given syntax.
+func Parse(text string, syntax Syntax) (*Template, error) {
+	t := &Template{Syntax: syntax}
+	if err := t.parse(text); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Load parses a template from value, which is either an inline template or
+// a path prefixed with file://.
+func Load(value string, syntax Syntax) (*Template, error) {
+	t := &Template{Syntax: syntax}
+	if err := t.Set(value); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// String returns the value the template was loaded from.
+func (t *Template) String() string {
+	if t == nil {
+		return ""
+	}
+	return t.source
+}
+
+// Set implements flag.Value by loading the template from value.
+func (t *Template) Set(value string) error {
+	text := value
+	if strings.HasPrefix(value, FilePrefix) {
+		data, err := ioutil.ReadFile(strings.TrimPrefix(value, FilePrefix))
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	if err := t.parse(text); err != nil {
+		return err
+	}
+	t.source = value
+	return nil
+}
+
+// IsSet reports whether a template has been parsed.
+func (t *Template) IsSet() bool {
+	return t != nil && t.tmpl != nil
+}
+
+func (t *Template) parse(text string) error {
+	syntax := t.Syntax
+	if syntax == Auto {
+		syntax = detect(text)
+	}
+	src := text
+	if syntax == Handlebars {
+		var err error
+		src, err = translate(text)
+		if err != nil {
+			return err
+		}
+	}
+	tmpl, err := gotemplate.New("template").
+		Funcs(Funcs).
+		Funcs(gotemplate.FuncMap{orEmptyFunc: orEmpty}).
+		Option("missingkey=zero").
+		Parse(src)
+	if err != nil {
+		return err
+	}
+	for _, tt := range tmpl.Templates() {
+		if tt.Tree != nil {
+			emptyMissing(tt.Tree, tt.Tree.Root)
+		}
+	}
+	t.text = text
+	t.tmpl = tmpl
+	return nil
+}
+
+// orEmptyFunc is the name of the function emptyMissing appends to actions.
+const orEmptyFunc = "_orEmpty"
+
+// orEmpty returns the empty string for missing values.
+func orEmpty(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+// emptyMissing makes the actions below n print missing keys as the empty
+// string like Handlebars does instead of "<no value>".
+func emptyMissing(tree *parse.Tree, n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			emptyMissing(tree, c)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(orEmptyFunc).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		emptyMissing(tree, n.List)
+		emptyMissing(tree, n.ElseList)
+	case *parse.RangeNode:
+		emptyMissing(tree, n.List)
+		emptyMissing(tree, n.ElseList)
+	case *parse.WithNode:
+		emptyMissing(tree, n.List)
+		emptyMissing(tree, n.ElseList)
+	}
+}
+
+// Execute renders the template with data to w.
+func (t *Template) Execute(w io.Writer, data interface{}) error {
+	if !t.IsSet() {
+		return fmt.Errorf("template is not set")
+	}
+	return t.tmpl.Execute(w, data)
+}
+
+// Render renders the template with the data returned by Data for d.
+func (t *Template) Render(d plug.Drone) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, Data(d)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderUsage is like Render but also registers render errors as usage
+// errors for the option t is bound to.
+func (t *Template) RenderUsage(log *plug.Logger, d plug.Drone) (string, error) {
+	out, err := t.Render(d)
+	if err != nil {
+		log.Usagef(t, "template error: %v", err)
+	}
+	return out, err
+}
+
+// Data returns the template data for d. Keys are lower case like in the
+// templates of drone-slack: repo, build, commit, stage and step. The build
+// also has the commit keys of drone-slack, such as build.commit and
+// build.author. Missing keys render as the empty string.
+func Data(d plug.Drone) map[string]interface{} {
+	return map[string]interface{}{
+		"repo": map[string]interface{}{
+			"owner":    d.Repo.Owner,
+			"name":     d.Repo.Name,
+			"fullname": fullName(d.Repo),
+			"link":     d.Repo.Link,
+			"avatar":   d.Repo.Avatar,
+			"branch":   d.Repo.Branch,
+			"private":  d.Repo.Private,
+			"trusted":  d.Repo.Trusted,
+		},
+		"build": map[string]interface{}{
+			"number":   d.Build.Number,
+			"event":    d.Build.Event,
+			"status":   d.Build.Status,
+			"deploy":   d.Build.Deploy,
+			"created":  d.Build.Created,
+			"started":  d.Build.Started,
+			"finished": d.Build.Finished,
+			"link":     d.Build.Link,
+			// drone-slack names
+			"tag":      refName(d.Commit.Ref, "refs/tags/"),
+			"pull":     pullNumber(d.Commit.Ref),
+			"commit":   d.Commit.Sha,
+			"ref":      d.Commit.Ref,
+			"branch":   d.Commit.Branch,
+			"message":  d.Commit.Message,
+			"author":   d.Commit.Author.Name,
+			"email":    d.Commit.Author.Email,
+			"avatar":   d.Commit.Author.Avatar,
+			"deployTo": d.Build.Deploy,
+		},
+		"commit": map[string]interface{}{
+			"sha":     d.Commit.Sha,
+			"ref":     d.Commit.Ref,
+			"link":    d.Commit.Link,
+			"branch":  d.Commit.Branch,
+			"message": d.Commit.Message,
+			"author": map[string]interface{}{
+				"name":   d.Commit.Author.Name,
+				"email":  d.Commit.Author.Email,
+				"avatar": d.Commit.Author.Avatar,
+			},
+		},
+		"stage": map[string]interface{}{
+			"name":     d.Stage.Name,
+			"number":   d.Stage.Number,
+			"kind":     d.Stage.Kind,
+			"type":     d.Stage.Type,
+			"status":   d.Stage.Status,
+			"started":  d.Stage.Started,
+			"finished": d.Stage.Finished,
+			"machine":  d.Stage.Machine,
+			"os":       d.Stage.OS,
+			"arch":     d.Stage.Arch,
+			"variant":  d.Stage.Variant,
+		},
+		"step": map[string]interface{}{
+			"name":   d.Step.Name,
+			"number": d.Step.Number,
+		},
+	}
+}
+
+func fullName(r plug.Repo) string {
+	if r.Owner == "" && r.Name == "" {
+		return ""
+	}
+	return r.Owner + "/" + r.Name
+}
+
+// refName returns ref without prefix or the empty string if ref does not
+// start with prefix.
+func refName(ref, prefix string) string {
+	if !strings.HasPrefix(ref, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(ref, prefix)
+}
+
+// pullNumber returns the pull request number of a refs/pull/N/head ref.
+func pullNumber(ref string) string {
+	name := refName(ref, "refs/pull/")
+	if i := strings.IndexByte(name, '/'); i > 0 {
+		return name[:i]
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package template_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drone-plug/drone-plugins-go/plug"
+	"github.com/drone-plug/drone-plugins-go/plug/template"
+)
+
+var drone = plug.Drone{
+	Repo: plug.Repo{Owner: "octocat", Name: "hello-world"},
+	Build: plug.Build{
+		Number:   42,
+		Event:    "push",
+		Status:   "success",
+		Started:  1500000000,
+		Finished: 1500000125,
+	},
+	Commit: plug.Commit{
+		Sha:     "0123456789abcdef",
+		Branch:  "main",
+		Message: "Add a feature\n\nLong description",
+		Author:  plug.Author{Name: "octocat"},
+	},
+	Stage: plug.Stage{Name: "default"},
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"build {{build.number}} of {{repo.fullname}}", "build 42 of octocat/hello-world"},
+		{"{{#success build.status}}ok{{else}}failed{{/success}}", "ok"},
+		{"{{#failure build.status}}failed{{else}}ok{{/failure}}", "ok"},
+		{"{{#if commit.author.name}}by {{commit.author.name}}{{/if}}", "by octocat"},
+		{"{{#unless build.deploy}}no deploy{{/unless}}", "no deploy"},
+		{"{{shortsha commit.sha}} {{truncate commit.message 5}}", "01234567 Add a"},
+		{"{{duration build.started build.finished}}", "2m5s"},
+		{"{{uppercase (truncate repo.name 5)}}", "HELLO"},
+		{"{{uppercasefirst stage.name}}", "Default"},
+		{`{{regexReplace "-" repo.name "_"}}`, "hello_world"},
+		{"{{! comment }}{{{build.event}}}", "push"},
+		{"{{#with commit.author}}{{name}}{{/with}}", "octocat"},
+		{"build {{.build.number}} {{if success .build.status}}ok{{end}}", "build 42 ok"},
+		{"[{{build.missing}}]", "[]"},
+		{"[{{.commit.missing}}]", "[]"},
+	}
+	for _, tc := range tests {
+		tmpl, err := template.Parse(tc.text, template.Auto)
+		if err != nil {
+			t.Errorf("%s: %v", tc.text, err)
+			continue
+		}
+		out, err := tmpl.Render(drone)
+		if err != nil {
+			t.Errorf("%s: %v", tc.text, err)
+			continue
+		}
+		if out != tc.expected {
+			t.Errorf("%s: got %q, expected %q", tc.text, out, tc.expected)
+		}
+	}
+}
+
+func TestEach(t *testing.T) {
+	tmpl, err := template.Parse("{{#each repo}}{{@key}}={{this}} {{/each}}", template.Handlebars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tmpl.Render(drone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "avatar= branch= fullname=octocat/hello-world link= name=hello-world owner=octocat private=false trusted=false "
+	if out != expected {
+		t.Errorf("got %q, expected %q", out, expected)
+	}
+}
+
+// slackTemplate is a message template written for drone-slack.
+const slackTemplate = `*{{#success build.status}}✔{{ else }}✘{{/success}} <{{ build.link }}|Build #{{ build.number }}> (type: ` + "`{{ build.event }}`" + `)*
+Commit: <https://github.com/{{ repo.owner }}/{{ repo.name }}/commit/{{ build.commit }}|{{ truncate build.commit 8 }}>
+Branch: {{ build.branch }}{{#if build.tag}} tag: {{ build.tag }}{{/if}}
+Author: {{ build.author }}{{ build.unknown }}
+{{ build.message }}`
+
+func TestDroneSlackTemplate(t *testing.T) {
+	tmpl, err := template.Parse(slackTemplate, template.Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := drone
+	d.Build.Link = "https://drone.example.com/octocat/hello-world/42"
+	d.Commit.Ref = "refs/tags/v1.0.0"
+	out, err := tmpl.Render(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "*✔ <https://drone.example.com/octocat/hello-world/42|Build #42> (type: `push`)*\n" +
+		"Commit: <https://github.com/octocat/hello-world/commit/0123456789abcdef|01234567>\n" +
+		"Branch: main tag: v1.0.0\n" +
+		"Author: octocat\n" +
+		"Add a feature\n\nLong description"
+	if out != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{"{{#if build.status}}", "{{/if}}", "{{#nope x}}{{/nope}}", "{{build.number"} {
+		if _, err := template.Parse(text, template.Handlebars); err == nil {
+			t.Errorf("%s: expected an error", text)
+		}
+	}
+}
+
+type templatePlugin struct {
+	Template template.Template
+	Drone    plug.Drone
+}
+
+func (p *templatePlugin) SetFlags(fs *plug.FlagSet) {
+	fs.Var(&p.Template, "template", "message template")
+	fs.DroneVar(&p.Drone)
+}
+
+func (p *templatePlugin) Exec(ctx context.Context, log *plug.Logger) error {
+	out, err := p.Template.RenderUsage(log, p.Drone)
+	if err != nil {
+		return plug.ErrUsageError
+	}
+	log.Println(out)
+	return nil
+}
+
+func TestTemplateOption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "message.tmpl")
+	if err := ioutil.WriteFile(filename, []byte("{{repo.name}} #{{build.number}}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	res, err := plug.Invoke(context.Background(), &templatePlugin{}, plug.Settings{
+		"template": template.FilePrefix + filename,
+	}, plug.WithDrone(drone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Log != "hello-world #42\n" {
+		t.Errorf("unexpected output: %q", res.Log)
+	}
+
+	_, err = plug.Invoke(context.Background(), &templatePlugin{}, plug.Settings{
+		"template": "{{#if build.status}}",
+	}, plug.WithDrone(drone))
+	if err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+
+	_, err = plug.Invoke(context.Background(), &templatePlugin{}, plug.Settings{
+		"template": `{{datetime build.started "2006" "Nowhere/Invalid"}}`,
+	}, plug.WithDrone(drone))
+	if err == nil || len(err.(*plug.ExecError).UsageErrors["template"]) != 1 {
+		t.Errorf("expected a template usage error, got %v", err)
+	}
+}
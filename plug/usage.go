@@ -18,7 +18,9 @@ func (s *Service) envUsage() {
 
 func fmtDroneYMLName(envname string) string {
 	stripped := strings.ToLower(envname)
-	stripped = strings.TrimPrefix(stripped, "plugin_")
+	if strings.HasPrefix(stripped, "plugin_") {
+		return strings.TrimPrefix(stripped, "plugin_")
+	}
 	stripped = strings.TrimPrefix(stripped, "drone_")
 	// todo: handle plugin_ and drone_ in some way
 	return stripped
@@ -47,11 +49,16 @@ func (s *Service) usageFuncYml() {
 	}
 
 	writeUsage := func(e fenv.EnvFlag) {
+		if _, ok := s.fileFlags[e.Flag.Name]; ok {
+			return
+		}
 
 		var pluginNames, rawNames []string
 	nameLoop:
 		for _, v := range e.Names {
-			if strings.HasPrefix(v, "DRONE_") {
+			// drone variables are only shown when set in another way,
+			// for example by GitFallback.
+			if strings.HasPrefix(v, "DRONE_") && s.setBy[e.Flag.Name] == "" {
 				continue nameLoop
 			}
 			if strings.HasPrefix(v, "PLUGIN_") {
@@ -77,7 +84,15 @@ func (s *Service) usageFuncYml() {
 		}
 
 		// w.Append([]string{"", "", e.Flag.Usage})
-		if e.IsSelfSet {
+		if f := s.fs.Lookup(e.Flag.Name + "-file"); f != nil && s.fileFlags[f.Name] != "" {
+			add("file option", fmtDroneYMLName(settingEnvName(f.Name)))
+		}
+		if aliases := s.aliases[e.Flag.Name]; len(aliases) > 0 {
+			add("alias", strings.Join(aliases, ", ")+" (deprecated)")
+		}
+		if setBy := s.setBy[e.Flag.Name]; setBy != "" {
+			add("set by", setBy)
+		} else if e.IsSelfSet {
 			add("set by", setName)
 		} else if e.IsSet {
 			add("set by flag", e.Flag.Name)
@@ -88,28 +103,37 @@ func (s *Service) usageFuncYml() {
 				add("env value", e.Value)
 			}
 		}
-		if e.Flag.Value.String() != "" {
+		// values read from files are usually secrets
+		if e.Flag.Value.String() != "" && !strings.HasPrefix(s.setBy[e.Flag.Name], "file ") {
 			add("value", e.Flag.Value.String())
 		}
 		if e.Err != nil {
 			add("**ERROR**", e.Err.Error())
 		}
-		if errs, ok := s.usageErrors[e.Flag.Name]; ok {
+		if errs := s.flagUsageErrors(e.Flag.Name); len(errs) > 0 {
 			add("**USAGE ERROR**", strings.Join(errs, "\n"))
 		}
+		if warnings := s.flagWarnings(e.Flag.Name); len(warnings) > 0 {
+			add("**WARNING**", strings.Join(warnings, "\n"))
+		}
 	}
 	var (
 		unsetFlags []fenv.EnvFlag // flags which are not set
 		defFlags   []fenv.EnvFlag // flags which are set to their default value
 		setFlags   []fenv.EnvFlag // flags which are set
+		warnFlags  []fenv.EnvFlag // flags which have warnings
 		errFlags   []fenv.EnvFlag // flags which failed to set due to parsing or validation errors
 	)
 
 	s.es.VisitAll(func(e fenv.EnvFlag) {
-		if e.Err != nil || len(s.usageErrors[e.Flag.Name]) > 0 {
+		if e.Err != nil || len(s.flagUsageErrors(e.Flag.Name)) > 0 {
 			errFlags = append(errFlags, e)
 			return
 		}
+		if len(s.flagWarnings(e.Flag.Name)) > 0 {
+			warnFlags = append(warnFlags, e)
+			return
+		}
 		if !e.IsSet {
 			if e.Flag.Value.String() != "" {
 				defFlags = append(defFlags, e)
@@ -145,12 +169,30 @@ func (s *Service) usageFuncYml() {
 			writeUsage(f)
 		}
 	}
+	globalWarnings := s.flagWarnings("")
+	if len(warnFlags) > 0 || len(globalWarnings) > 0 {
+		// warnings do not fail the build so they get their own section
+		// which is shown outside of debug mode as well.
+		w.Append([]string{"WARNINGS", "----------"})
+		for _, f := range warnFlags {
+			writeUsage(f)
+		}
+		if len(globalWarnings) > 0 {
+			sep()
+			add("**WARNING**", strings.Join(globalWarnings, "\n"))
+		}
+	}
 	if len(errFlags) > 0 {
 		debugHeader("ERRORS")
 		for _, f := range errFlags {
 			writeUsage(f)
 		}
 	}
+	if globalErrs := s.flagUsageErrors(""); len(globalErrs) > 0 {
+		debugHeader("GLOBAL")
+		sep()
+		add("**USAGE ERROR**", strings.Join(globalErrs, "\n"))
+	}
 
 	s.log.Println("plugin usage:")
 
@@ -158,3 +200,34 @@ func (s *Service) usageFuncYml() {
 	s.log.Println("\n" + b.String())
 
 }
+
+// flagUsageErrors returns the usage errors registered for a flag.
+func (s *Service) flagUsageErrors(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usageErrors[name]
+}
+
+// flagWarnings returns the warnings registered for a flag.
+func (s *Service) flagWarnings(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.warnings[name]
+}
+
+// warningsUsage prints the registered warnings, it is used when the full
+// usage table is not shown.
+func (s *Service) warningsUsage() {
+	var lines []string
+	s.es.VisitAll(func(e fenv.EnvFlag) {
+		for _, msg := range s.flagWarnings(e.Flag.Name) {
+			lines = append(lines, fmtFlagMessage(&e, "warning", strings.TrimSpace(msg)))
+		}
+	})
+	for _, msg := range s.flagWarnings("") {
+		lines = append(lines, "plugin warning: "+strings.TrimSpace(msg))
+	}
+	for _, line := range lines {
+		s.log.Println(line)
+	}
+}